@@ -0,0 +1,184 @@
+package cbl
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AccessLogJSON   = "json"
+	AccessLogLogfmt = "logfmt"
+)
+
+// AccessLogger access log output, *log.Logger satisfied
+type AccessLogger interface {
+	Println(v ...interface{})
+}
+
+// AccessLogOptions options of AccessLogMiddleware
+type AccessLogOptions struct {
+	Format        string                      // AccessLogJSON (default) or AccessLogLogfmt
+	Logger        AccessLogger                // stdout if nil
+	SampleRate    float64                     // (0, 1) sample ratio of normal requests, 0 log all. error and slow requests always logged
+	SkipPaths     []string                    // not logged path or route, e.g. /health
+	SlowThreshold time.Duration               // request slower marked `slow`, 0 disabled
+	UserID        func(c *gin.Context) string // user of request, session user or jwt subject if nil
+}
+
+// AccessLogEntry fields of access log line
+type AccessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"` // route template, e.g. /orders/:id
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Code      *int      `json:"code,omitempty"` // business code of Resp, nil if not Resp
+	LatencyMs float64   `json:"latency_ms"`
+	Bytes     int       `json:"bytes"`
+	ClientIP  string    `json:"client_ip"`
+	UserID    string    `json:"user_id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Slow      bool      `json:"slow,omitempty"`
+}
+
+// respCodePrefix Resp json always begin with code, business code parsed from response head
+var respCodePrefix = []byte(`{"code":`)
+
+// AccessLogMiddleware log a line per request, e.g. logfmt:
+//
+//	time=2020-06-25T10:00:00+08:00 method=GET route=/orders/:id path=/orders/1 status=200 code=0 latency_ms=1.2 bytes=38 client_ip=127.0.0.1 user_id=u1 request_id=ab12
+func AccessLogMiddleware(opts *AccessLogOptions) gin.HandlerFunc {
+	o := AccessLogOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Logger == nil {
+		o.Logger = log.New(os.Stdout, "", 0)
+	}
+	if o.UserID == nil {
+		o.UserID = requestUserID
+	}
+	skip := make(map[string]struct{}, len(o.SkipPaths))
+	for _, p := range o.SkipPaths {
+		skip[p] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+		if _, ok := skip[c.FullPath()]; ok {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		w := &bodyWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}, limit: 32}
+		c.Writer = w
+		c.Next()
+		latency := time.Since(start)
+
+		e := &AccessLogEntry{
+			Time:      start,
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Status:    w.Status(),
+			Code:      respCode(w.body.Bytes()),
+			LatencyMs: float64(latency.Microseconds()) / 1000,
+			Bytes:     w.Size(),
+			ClientIP:  c.ClientIP(),
+			UserID:    o.UserID(c),
+			RequestID: RequestID(c),
+			Slow:      o.SlowThreshold > 0 && latency >= o.SlowThreshold,
+		}
+		if e.Bytes < 0 {
+			e.Bytes = 0
+		}
+		failed := e.Status >= http.StatusInternalServerError || (e.Code != nil && *e.Code != codeOK)
+		if !e.Slow && !failed && o.SampleRate > 0 && o.SampleRate < 1 && rand.Float64() >= o.SampleRate {
+			return
+		}
+
+		if o.Format == AccessLogLogfmt {
+			o.Logger.Println(e.logfmt())
+			return
+		}
+		bs, _ := json.Marshal(e)
+		o.Logger.Println(string(bs))
+	}
+}
+
+func requestUserID(c *gin.Context) string {
+	if s := GetSession(c); s != nil {
+		return s.UserID
+	}
+	if claims := GetJWTClaims(c); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+// respCode parse business code from Resp json head, e.g. {"code":40400,...
+func respCode(head []byte) *int {
+	if !bytes.HasPrefix(head, respCodePrefix) {
+		return nil
+	}
+	head = head[len(respCodePrefix):]
+	end := bytes.IndexAny(head, ",}")
+	if end < 0 {
+		return nil
+	}
+	code, err := strconv.Atoi(string(head[:end]))
+	if err != nil {
+		return nil
+	}
+	return &code
+}
+
+func (e *AccessLogEntry) logfmt() string {
+	var b strings.Builder
+	kv := func(k string, v string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+
+	kv("time", e.Time.Format(time.RFC3339))
+	kv("method", e.Method)
+	kv("route", e.Route)
+	kv("path", e.Path)
+	kv("status", strconv.Itoa(e.Status))
+	if e.Code != nil {
+		kv("code", strconv.Itoa(*e.Code))
+	}
+	kv("latency_ms", strconv.FormatFloat(e.LatencyMs, 'f', -1, 64))
+	kv("bytes", strconv.Itoa(e.Bytes))
+	kv("client_ip", e.ClientIP)
+	if e.UserID != "" {
+		kv("user_id", e.UserID)
+	}
+	if e.RequestID != "" {
+		kv("request_id", e.RequestID)
+	}
+	if e.Slow {
+		kv("slow", "true")
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package cbl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memAccessLogger struct {
+	sync.Mutex
+	lines []string
+}
+
+func (l *memAccessLogger) Println(v ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(v...))
+}
+
+func (l *memAccessLogger) take() []string {
+	l.Lock()
+	defer l.Unlock()
+	lines := l.lines
+	l.lines = nil
+	return lines
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	logger := &memAccessLogger{}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AccessLogMiddleware(&AccessLogOptions{
+		Logger:        logger,
+		SkipPaths:     []string{"/health"},
+		SlowThreshold: 20 * time.Millisecond,
+		UserID:        func(c *gin.Context) string { return c.GetHeader("X-User") },
+	}), RequestIDMiddleware(nil))
+	router.GET("/orders/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			ErrorResponse(c, ErrorNotFound)
+			return
+		}
+		SuccessResponse(c, c.Param("id"))
+	})
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(25 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	router.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	do := func(path string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", "u1")
+		req.Header.Set(RequestIDHeader, "req-1")
+		router.ServeHTTP(w, req)
+	}
+
+	do("/orders/1")
+	do("/orders/0")
+	do("/slow")
+	do("/health")
+	lines := logger.take()
+	require.Len(t, lines, 3)
+
+	var e AccessLogEntry
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &e))
+	assert.Equal(t, "GET", e.Method)
+	assert.Equal(t, "/orders/:id", e.Route)
+	assert.Equal(t, "/orders/1", e.Path)
+	assert.Equal(t, http.StatusOK, e.Status)
+	require.NotNil(t, e.Code)
+	assert.Equal(t, 0, *e.Code)
+	assert.Equal(t, len(`{"code":0,"data":"1","error":""}`), e.Bytes)
+	assert.Equal(t, "u1", e.UserID)
+	assert.Equal(t, "req-1", e.RequestID)
+	assert.False(t, e.Slow)
+
+	e = AccessLogEntry{}
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, http.StatusNotFound, e.Status)
+	assert.Equal(t, CodeNotFound, *e.Code)
+
+	e = AccessLogEntry{}
+	require.Nil(t, json.Unmarshal([]byte(lines[2]), &e))
+	assert.Nil(t, e.Code)
+	assert.True(t, e.Slow)
+}
+
+func TestAccessLogLogfmtSampling(t *testing.T) {
+	logger := &memAccessLogger{}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AccessLogMiddleware(&AccessLogOptions{
+		Format:     AccessLogLogfmt,
+		Logger:     logger,
+		SampleRate: 1e-9, // only errors
+	}))
+	router.GET("/orders/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			ErrorResponse(c, "order not exist")
+			return
+		}
+		SuccessResponse(c, c.Param("id"))
+	})
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/orders/0"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, req)
+	}
+	lines := logger.take()
+	require.Len(t, lines, 1)
+	assert.True(t, strings.Contains(lines[0], " method=GET route=/orders/:id path=/orders/0 status=200 code=1 latency_ms="), lines[0])
+	assert.False(t, strings.Contains(lines[0], "user_id="))
+}
+
+func TestRespCode(t *testing.T) {
+	code := respCode([]byte(`{"code":40400,"data":null`))
+	require.NotNil(t, code)
+	assert.Equal(t, 40400, *code)
+	assert.Nil(t, respCode([]byte(`{"code":4040`)))
+	assert.Nil(t, respCode([]byte(`plain text`)))
+}
@@ -0,0 +1,396 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// -----------------------------------------------------------------------------
+// circuit breaker
+// closed: commands pass through, consecutive failures (network/timeout, not redis
+// reply errors) reach threshold trip it to open.
+// open: commands fail fast with ErrCircuitOpen, object/string getters served from
+// near-cache if enabled. after OpenTimeout half-open.
+// half-open: one probe command pass through, success close it, failure open again.
+// -----------------------------------------------------------------------------
+
+var (
+	ErrCircuitOpen = fmt.Errorf("cache circuit breaker open")
+)
+
+// BreakerState circuit breaker state
+type BreakerState int32
+
+const (
+	BreakerClosed   BreakerState = iota // normal
+	BreakerOpen                         // fail fast
+	BreakerHalfOpen                     // probing recovery
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOptions circuit breaker options
+type BreakerOptions struct {
+	FailureThreshold int           // consecutive failures trip the breaker, default 5
+	OpenTimeout      time.Duration // open duration before probing, default 10s
+	NearCacheSize    int           // max entries of local near-cache, 0 for disabled
+	NearCacheTTL     time.Duration // max staleness of near-cache value served while open, default 1m
+	// called outside breaker lock, may be concurrent
+	OnStateChange func(from BreakerState, to BreakerState)
+}
+
+// BreakerStats breaker state for health check
+type BreakerStats struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"` // consecutive failures
+	OpenedAt time.Time    `json:"opened_at"`
+}
+
+type breaker struct {
+	opts BreakerOptions
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64 // increased by every state change
+	failures   int
+	openedAt   time.Time
+	probing    bool
+
+	near *nearCache
+}
+
+// admission command passed through breaker, result of command admitted in an older
+// generation (before state changed) is ignored, only probe finishes half-open.
+type admission struct {
+	b          *breaker
+	generation uint64
+	probe      bool
+}
+
+type admissionKey struct{}
+
+var currentBreaker atomic.Value // *breaker
+
+// EnableBreaker enable circuit breaker (replace the old one), work before or after InitCache
+func EnableBreaker(opts BreakerOptions) {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 10 * time.Second
+	}
+	if opts.NearCacheTTL <= 0 {
+		opts.NearCacheTTL = time.Minute
+	}
+	b := &breaker{opts: opts}
+	if opts.NearCacheSize > 0 {
+		b.near = newNearCache(opts.NearCacheSize, opts.NearCacheTTL)
+	}
+	currentBreaker.Store(b)
+}
+
+// DisableBreaker disable circuit breaker
+func DisableBreaker() {
+	currentBreaker.Store((*breaker)(nil))
+}
+
+func loadBreaker() *breaker {
+	b, _ := currentBreaker.Load().(*breaker)
+	return b
+}
+
+// GetBreakerStats breaker state, closed if breaker not enabled
+func GetBreakerStats() BreakerStats {
+	b := loadBreaker()
+	if b == nil {
+		return BreakerStats{State: BreakerClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{State: b.currentState(), Failures: b.failures, OpenedAt: b.openedAt}
+}
+
+// Health for health check, ErrCircuitOpen if breaker open (without touching redis), else ping result
+func Health() error {
+	if GetBreakerStats().State == BreakerOpen {
+		return ErrCircuitOpen
+	}
+	return redisClient.Ping().Err()
+}
+
+// currentState open turn to half-open after timeout, must hold lock
+func (b *breaker) currentState() BreakerState {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.opts.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// setState must hold lock, return state change callback which must be called after unlock
+// (callback may call GetBreakerStats or Health)
+func (b *breaker) setState(to BreakerState) func() {
+	from := b.state
+	b.state = to
+	if to == BreakerOpen {
+		b.openedAt = time.Now()
+	}
+	if from == to {
+		return func() {}
+	}
+	b.generation++
+	return func() {
+		if b.opts.OnStateChange != nil {
+			b.opts.OnStateChange(from, to)
+		}
+	}
+}
+
+// allow check command can pass through
+func (b *breaker) allow() (admission, error) {
+	b.mu.Lock()
+	notify := func() {}
+	a := admission{b: b}
+	switch b.currentState() {
+	case BreakerOpen:
+		b.mu.Unlock()
+		return a, ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return a, ErrCircuitOpen
+		}
+		notify = b.setState(BreakerHalfOpen)
+		b.probing = true
+		a.probe = true
+	}
+	a.generation = b.generation
+	b.mu.Unlock()
+
+	notify()
+	return a, nil
+}
+
+func (b *breaker) done(a admission, failed bool) {
+	b.mu.Lock()
+	if a.generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	notify := func() {}
+	if a.probe {
+		b.probing = false
+	}
+	if !failed {
+		b.failures = 0
+		notify = b.setState(BreakerClosed)
+	} else {
+		b.failures++
+		if a.probe || b.failures >= b.opts.FailureThreshold {
+			notify = b.setState(BreakerOpen)
+		}
+	}
+	b.mu.Unlock()
+
+	notify()
+}
+
+// isFailure redis unavailable, redis replies (include redis.Nil) mean server alive
+func isFailure(err error) bool {
+	switch err {
+	case nil, redis.Nil, ErrCircuitOpen, context.Canceled:
+		return false
+	}
+	if _, ok := err.(redis.Error); ok {
+		return false
+	}
+	return true
+}
+
+// breakerHook installed by InitCache, do nothing when breaker disabled
+type breakerHook struct{}
+
+func (breakerHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return admit(ctx)
+}
+
+func (breakerHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	a, ok := ctx.Value(admissionKey{}).(admission)
+	if !ok {
+		return nil
+	}
+	a.b.done(a, isFailure(cmd.Err()))
+	if a.b.near != nil {
+		a.b.near.observe(cmd)
+	}
+	return nil
+}
+
+func (breakerHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return admit(ctx)
+}
+
+func (breakerHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	a, ok := ctx.Value(admissionKey{}).(admission)
+	if !ok {
+		return nil
+	}
+	failed := false
+	for _, cmd := range cmds {
+		if isFailure(cmd.Err()) {
+			failed = true
+		}
+	}
+	a.b.done(a, failed)
+	if a.b.near != nil {
+		for _, cmd := range cmds {
+			a.b.near.observe(cmd)
+		}
+	}
+	return nil
+}
+
+// admit carry admission in context to After hooks (breaker may be replaced in between)
+func admit(ctx context.Context) (context.Context, error) {
+	b := loadBreaker()
+	if b == nil {
+		return ctx, nil
+	}
+	a, err := b.allow()
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, admissionKey{}, a), nil
+}
+
+// getBytes GET with near-cache fallback while breaker open
+func getBytes(realKey string) ([]byte, error) {
+	bs, err := redisClient.Get(realKey).Bytes()
+	if err == ErrCircuitOpen {
+		if b := loadBreaker(); b != nil && b.near != nil {
+			if v, ok := b.near.get(realKey); ok {
+				return v, nil
+			}
+		}
+	}
+	return bs, err
+}
+
+// -----------------------------------------------------------------------------
+// near-cache
+// recent GET/SET plain values kept in process, DEL/expire commands evict them.
+// -----------------------------------------------------------------------------
+
+type nearEntry struct {
+	value []byte
+	at    time.Time
+}
+
+type nearCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]nearEntry
+}
+
+func newNearCache(size int, ttl time.Duration) *nearCache {
+	return &nearCache{size: size, ttl: ttl, entries: make(map[string]nearEntry, size)}
+}
+
+func (c *nearCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.at) > c.ttl {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *nearCache) set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		// evict an arbitrary entry, prefer the stale one
+		victim := ""
+		for k, e := range c.entries {
+			victim = k
+			if time.Since(e.at) > c.ttl {
+				break
+			}
+		}
+		delete(c.entries, victim)
+	}
+	c.entries[key] = nearEntry{value: value, at: time.Now()}
+}
+
+func (c *nearCache) del(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		delete(c.entries, k)
+	}
+}
+
+func toBytes(v interface{}) ([]byte, bool) {
+	switch v := v.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	}
+	return nil, false
+}
+
+func (c *nearCache) observe(cmd redis.Cmder) {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return
+	}
+
+	switch cmd.Name() {
+	case "get":
+		sc, ok := cmd.(*redis.StringCmd)
+		if !ok {
+			return
+		}
+		if sc.Err() == nil {
+			c.set(key, []byte(sc.Val()))
+		} else if sc.Err() == redis.Nil {
+			c.del(key)
+		}
+	case "set":
+		if len(args) < 3 || cmd.Err() != nil {
+			c.del(key)
+			return
+		}
+		if v, ok := toBytes(args[2]); ok {
+			c.set(key, v)
+		}
+	case "del", "unlink", "expire", "pexpire", "expireat", "pexpireat", "incr", "incrby", "decr", "decrby":
+		for _, arg := range args[1:] {
+			if k, ok := arg.(string); ok {
+				c.del(k)
+			}
+		}
+	}
+}
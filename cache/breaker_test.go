@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerStateMachine(t *testing.T) {
+	var (
+		changes = []BreakerState{}
+		netErr  = errors.New("dial tcp: connection refused")
+	)
+	EnableBreaker(BreakerOptions{
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+		OnStateChange: func(from BreakerState, to BreakerState) {
+			changes = append(changes, to)
+		},
+	})
+	defer DisableBreaker()
+	b := loadBreaker()
+
+	// redis replies are not failures
+	assert.False(t, isFailure(redis.Nil))
+	assert.False(t, isFailure(redis.TxFailedErr))
+	assert.True(t, isFailure(netErr))
+
+	call := func(failed bool) {
+		a, err := b.allow()
+		require.Nil(t, err)
+		b.done(a, failed)
+	}
+
+	for i := 0; i < 2; i++ {
+		call(true)
+	}
+	call(false) // reset consecutive failures
+	assert.Equal(t, 0, GetBreakerStats().Failures)
+
+	stale, err := b.allow()
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		call(true)
+	}
+	assert.Equal(t, BreakerOpen, GetBreakerStats().State)
+	_, err = b.allow()
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, ErrCircuitOpen, Health())
+	b.done(stale, false) // admitted before opened, ignored
+	assert.Equal(t, BreakerOpen, GetBreakerStats().State)
+
+	// half-open, only one probe
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, GetBreakerStats().State)
+	probe, err := b.allow()
+	require.Nil(t, err)
+	_, err = b.allow()
+	assert.Equal(t, ErrCircuitOpen, err)
+	b.done(stale, true) // not the probe, ignored
+	assert.Equal(t, BreakerHalfOpen, GetBreakerStats().State)
+	b.done(probe, true) // probe failed
+	assert.Equal(t, BreakerOpen, GetBreakerStats().State)
+
+	time.Sleep(60 * time.Millisecond)
+	call(false) // probe succeeded
+	assert.Equal(t, BreakerClosed, GetBreakerStats().State)
+
+	assert.Equal(t, []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}, changes)
+}
+
+func TestBreakerStateChangeCallback(t *testing.T) {
+	var stats []BreakerStats
+	EnableBreaker(BreakerOptions{
+		FailureThreshold: 1,
+		OnStateChange: func(from BreakerState, to BreakerState) {
+			// called outside lock, not deadlock
+			stats = append(stats, GetBreakerStats())
+		},
+	})
+	defer DisableBreaker()
+	b := loadBreaker()
+
+	a, err := b.allow()
+	require.Nil(t, err)
+	b.done(a, true)
+	require.Equal(t, 1, len(stats))
+	assert.Equal(t, BreakerOpen, stats[0].State)
+}
+
+func TestBreakerNearCache(t *testing.T) {
+	key := "TestBreakerNearCache"
+	EnableBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute, NearCacheSize: 2})
+	defer DisableBreaker()
+
+	require.Nil(t, SetString(key, "hello", time.Minute))
+	defer Del(key)
+	v, err := GetString(key)
+	require.Nil(t, err)
+	assert.Equal(t, "hello", v)
+
+	// trip
+	b := loadBreaker()
+	a, _ := b.allow()
+	b.done(a, true)
+	assert.Equal(t, BreakerOpen, GetBreakerStats().State)
+
+	v, err = GetString(key)
+	require.Nil(t, err)
+	assert.Equal(t, "hello", v)
+	_, err = GetString(key + ".miss")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, ErrCircuitOpen, SetString(key, "world", time.Minute))
+	v, err = GetString(key) // rejected write not reach redis, near-cache still consistent
+	require.Nil(t, err)
+	assert.Equal(t, "hello", v)
+
+	// bounded
+	c := newNearCache(2, time.Minute)
+	c.set("a", []byte("1"))
+	c.set("b", []byte("2"))
+	c.set("c", []byte("3"))
+	assert.Equal(t, 2, len(c.entries))
+	_, ok := c.get("c")
+	assert.True(t, ok)
+}
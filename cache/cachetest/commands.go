@@ -0,0 +1,1369 @@
+package cachetest
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type command struct {
+	fn    func(c *cmdContext, args []string) interface{}
+	arity int // include command name, negative for at least
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// connection & server
+		"ping":     {cmdPing, -1},
+		"echo":     {func(c *cmdContext, args []string) interface{} { return args[0] }, 2},
+		"auth":     {func(c *cmdContext, args []string) interface{} { return okReply }, -2},
+		"dbsize":   {func(c *cmdContext, args []string) interface{} { return len(c.db.keys(c.now)) }, 1},
+		"flushdb":  {cmdFlushDB, -1},
+		"flushall": {cmdFlushAll, -1},
+
+		// keys
+		"del":       {cmdDel, -2},
+		"unlink":    {cmdDel, -2},
+		"exists":    {cmdExists, -2},
+		"type":      {cmdType, 2},
+		"keys":      {cmdKeys, 2},
+		"scan":      {cmdScan, -2},
+		"rename":    {cmdRename, 3},
+		"ttl":       {cmdTTL, 2},
+		"pttl":      {cmdTTL, 2},
+		"expire":    {cmdExpire, 3},
+		"pexpire":   {cmdExpire, 3},
+		"expireat":  {cmdExpire, 3},
+		"pexpireat": {cmdExpire, 3},
+		"persist":   {cmdPersist, 2},
+
+		// strings
+		"get":    {cmdGet, 2},
+		"set":    {cmdSet, -3},
+		"setnx":  {cmdSetNX, 3},
+		"setex":  {cmdSetEX, 4},
+		"psetex": {cmdSetEX, 4},
+		"getset": {cmdGetSet, 3},
+		"mget":   {cmdMGet, -2},
+		"mset":   {cmdMSet, -3},
+		"incr":   {cmdIncr, 2},
+		"decr":   {cmdIncr, 2},
+		"incrby": {cmdIncr, 3},
+		"decrby": {cmdIncr, 3},
+		"strlen": {cmdStrlen, 2},
+		"append": {cmdAppend, 3},
+
+		// lists
+		"lpush":  {cmdPush, -3},
+		"rpush":  {cmdPush, -3},
+		"lpop":   {cmdPop, 2},
+		"rpop":   {cmdPop, 2},
+		"llen":   {cmdLLen, 2},
+		"lrange": {cmdLRange, 4},
+		"lindex": {cmdLIndex, 3},
+
+		// sets
+		"sadd":        {cmdSAdd, -3},
+		"srem":        {cmdSRem, -3},
+		"smembers":    {cmdSMembers, 2},
+		"scard":       {cmdSCard, 2},
+		"sismember":   {cmdSIsMember, 3},
+		"srandmember": {cmdSRandMember, -2},
+		"spop":        {cmdSPop, -2},
+		"smove":       {cmdSMove, 4},
+		"sunion":      {cmdSetAlgebra, -2},
+		"sinter":      {cmdSetAlgebra, -2},
+		"sdiff":       {cmdSetAlgebra, -2},
+		"sunionstore": {cmdSetAlgebraStore, -3},
+		"sinterstore": {cmdSetAlgebraStore, -3},
+		"sdiffstore":  {cmdSetAlgebraStore, -3},
+		"sscan":       {cmdSScan, -3},
+
+		// hashes
+		"hset":    {cmdHSet, -4},
+		"hmset":   {cmdHSet, -4},
+		"hsetnx":  {cmdHSetNX, 4},
+		"hget":    {cmdHGet, 3},
+		"hmget":   {cmdHMGet, -3},
+		"hgetall": {cmdHGetAll, 2},
+		"hdel":    {cmdHDel, -3},
+		"hkeys":   {cmdHKeys, 2},
+		"hvals":   {cmdHVals, 2},
+		"hlen":    {cmdHLen, 2},
+		"hexists": {cmdHExists, 3},
+		"hincrby": {cmdHIncrBy, 4},
+
+		// sorted sets
+		"zadd":             {cmdZAdd, -4},
+		"zrem":             {cmdZRem, -3},
+		"zscore":           {cmdZScore, 3},
+		"zcard":            {cmdZCard, 2},
+		"zcount":           {cmdZCount, 4},
+		"zincrby":          {cmdZIncrBy, 4},
+		"zrank":            {cmdZRank, 3},
+		"zrevrank":         {cmdZRank, 3},
+		"zrange":           {cmdZRange, -4},
+		"zrevrange":        {cmdZRange, -4},
+		"zrangebyscore":    {cmdZRangeByScore, -4},
+		"zrevrangebyscore": {cmdZRangeByScore, -4},
+		"zremrangebyscore": {cmdZRemRangeByScore, 4},
+
+		// geo
+		"geoadd":               {cmdGeoAdd, -5},
+		"geopos":               {cmdGeoPos, -2},
+		"geodist":              {cmdGeoDist, -4},
+		"georadius":            {cmdGeoRadius, -6},
+		"georadius_ro":         {cmdGeoRadius, -6},
+		"georadiusbymember":    {cmdGeoRadius, -5},
+		"georadiusbymember_ro": {cmdGeoRadius, -5},
+
+		// scripting
+		"eval":    {cmdEval, -3},
+		"evalsha": {cmdEval, -3},
+		"script":  {cmdScript, -2},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// connection & server
+// -----------------------------------------------------------------------------
+
+func cmdPing(c *cmdContext, args []string) interface{} {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return statusReply("PONG")
+}
+
+func cmdFlushDB(c *cmdContext, args []string) interface{} {
+	c.db.flush()
+	return okReply
+}
+
+func cmdFlushAll(c *cmdContext, args []string) interface{} {
+	for _, d := range c.srv.dbs {
+		d.flush()
+	}
+	return okReply
+}
+
+// -----------------------------------------------------------------------------
+// keys
+// -----------------------------------------------------------------------------
+
+func cmdDel(c *cmdContext, args []string) interface{} {
+	n := 0
+	for _, key := range args {
+		if c.del(key) {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(c *cmdContext, args []string) interface{} {
+	n := 0
+	for _, key := range args {
+		if c.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdType(c *cmdContext, args []string) interface{} {
+	it := c.lookup(args[0])
+	if it == nil {
+		return statusReply("none")
+	}
+	return statusReply(it.kind)
+}
+
+func cmdKeys(c *cmdContext, args []string) interface{} {
+	keys := []string{}
+	for _, key := range c.db.keys(c.now) {
+		if globMatch(args[0], key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func cmdScan(c *cmdContext, args []string) interface{} {
+	sa, err := parseScanArgs(args)
+	if err != nil {
+		return err
+	}
+	var filter func(string) bool
+	if sa.typ != "" {
+		filter = func(key string) bool {
+			it := c.lookup(key)
+			return it != nil && it.kind == sa.typ
+		}
+	}
+	return sa.scan(c.db.keys(c.now), filter)
+}
+
+func cmdRename(c *cmdContext, args []string) interface{} {
+	it := c.lookup(args[0])
+	if it == nil {
+		return errReply("ERR no such key")
+	}
+	c.del(args[0])
+	c.db.items[args[1]] = it
+	c.modified(args[1])
+	return okReply
+}
+
+func cmdTTL(c *cmdContext, args []string) interface{} {
+	it := c.lookup(args[0])
+	if it == nil {
+		return int64(-2)
+	}
+	if it.expireAt.IsZero() {
+		return int64(-1)
+	}
+	d := it.expireAt.Sub(c.now)
+	if c.name == "pttl" {
+		return int64(d / time.Millisecond)
+	}
+	// round like redis
+	return int64((d + 500*time.Millisecond) / time.Second)
+}
+
+func cmdExpire(c *cmdContext, args []string) interface{} {
+	n, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	it := c.lookup(args[0])
+	if it == nil {
+		return 0
+	}
+
+	var at time.Time
+	switch c.name {
+	case "expire":
+		at = c.now.Add(time.Duration(n) * time.Second)
+	case "pexpire":
+		at = c.now.Add(time.Duration(n) * time.Millisecond)
+	case "expireat":
+		at = time.Unix(n, 0)
+	case "pexpireat":
+		at = time.Unix(0, n*int64(time.Millisecond))
+	}
+	if !at.After(c.now) {
+		c.del(args[0])
+		return 1
+	}
+	it.expireAt = at
+	c.modified(args[0])
+	return 1
+}
+
+func cmdPersist(c *cmdContext, args []string) interface{} {
+	it := c.lookup(args[0])
+	if it == nil || it.expireAt.IsZero() {
+		return 0
+	}
+	it.expireAt = time.Time{}
+	c.modified(args[0])
+	return 1
+}
+
+// -----------------------------------------------------------------------------
+// strings
+// -----------------------------------------------------------------------------
+
+func cmdGet(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindString)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return nil
+	}
+	return it.str
+}
+
+// SET key value [EX seconds|PX milliseconds|KEEPTTL] [NX|XX] [GET]
+func cmdSet(c *cmdContext, args []string) interface{} {
+	var (
+		key, value   = args[0], args[1]
+		expire       time.Duration
+		nx, xx, keep bool
+		get          bool
+	)
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "keepttl":
+			keep = true
+		case "get":
+			get = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return err
+			}
+			if n <= 0 {
+				return errReply("ERR invalid expire time in set")
+			}
+			unit := time.Second
+			if strings.ToLower(args[i]) == "px" {
+				unit = time.Millisecond
+			}
+			expire = time.Duration(n) * unit
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx {
+		return errSyntax
+	}
+
+	old := c.lookup(key)
+	var oldValue interface{}
+	if get && old != nil {
+		if old.kind != kindString {
+			return errWrongType
+		}
+		oldValue = old.str
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		if get {
+			return oldValue
+		}
+		return nil
+	}
+
+	var expireAt time.Time
+	if keep && old != nil {
+		expireAt = old.expireAt
+	}
+	c.setString(key, value)
+	if expire > 0 {
+		expireAt = c.now.Add(expire)
+	}
+	c.db.items[key].expireAt = expireAt
+	if get {
+		return oldValue
+	}
+	return okReply
+}
+
+func cmdSetNX(c *cmdContext, args []string) interface{} {
+	if c.lookup(args[0]) != nil {
+		return 0
+	}
+	c.setString(args[0], args[1])
+	return 1
+}
+
+func cmdSetEX(c *cmdContext, args []string) interface{} {
+	unit := "ex"
+	if c.name == "psetex" {
+		unit = "px"
+	}
+	return cmdSet(c, []string{args[0], args[2], unit, args[1]})
+}
+
+func cmdGetSet(c *cmdContext, args []string) interface{} {
+	old := cmdGet(c, args[:1])
+	if _, ok := old.(errReply); ok {
+		return old
+	}
+	c.setString(args[0], args[1])
+	return old
+}
+
+func cmdMGet(c *cmdContext, args []string) interface{} {
+	values := make([]interface{}, 0, len(args))
+	for _, key := range args {
+		it := c.lookup(key)
+		if it == nil || it.kind != kindString {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, it.str)
+	}
+	return values
+}
+
+func cmdMSet(c *cmdContext, args []string) interface{} {
+	if len(args)%2 != 0 {
+		return errArgs(c.name)
+	}
+	for i := 0; i < len(args); i += 2 {
+		c.setString(args[i], args[i+1])
+	}
+	return okReply
+}
+
+func cmdIncr(c *cmdContext, args []string) interface{} {
+	var delta int64 = 1
+	if len(args) > 1 {
+		n, err := parseInt(args[1])
+		if err != nil {
+			return err
+		}
+		delta = n
+	}
+	if strings.HasPrefix(c.name, "decr") {
+		delta = -delta
+	}
+
+	it, err := c.write(args[0], kindString)
+	if err != nil {
+		return err
+	}
+	var v int64
+	if it.str != "" {
+		if v, err = parseInt(it.str); err != nil {
+			return err
+		}
+	}
+	v += delta
+	it.str = strconv.FormatInt(v, 10)
+	return v
+}
+
+func cmdStrlen(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindString)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	return len(it.str)
+}
+
+func cmdAppend(c *cmdContext, args []string) interface{} {
+	it, err := c.write(args[0], kindString)
+	if err != nil {
+		return err
+	}
+	it.str += args[1]
+	return len(it.str)
+}
+
+// -----------------------------------------------------------------------------
+// lists
+// -----------------------------------------------------------------------------
+
+func cmdPush(c *cmdContext, args []string) interface{} {
+	it, err := c.write(args[0], kindList)
+	if err != nil {
+		return err
+	}
+	for _, v := range args[1:] {
+		if c.name == "lpush" {
+			it.list = append([]string{v}, it.list...)
+		} else {
+			it.list = append(it.list, v)
+		}
+	}
+	return len(it.list)
+}
+
+func cmdPop(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindList)
+	if err != nil {
+		return err
+	}
+	if it == nil || len(it.list) == 0 {
+		return nil
+	}
+	c.modified(args[0])
+	var v string
+	if c.name == "lpop" {
+		v, it.list = it.list[0], it.list[1:]
+	} else {
+		v, it.list = it.list[len(it.list)-1], it.list[:len(it.list)-1]
+	}
+	return v
+}
+
+func cmdLLen(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindList)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	return len(it.list)
+}
+
+func cmdLRange(c *cmdContext, args []string) interface{} {
+	start, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	stop, err := parseInt(args[2])
+	if err != nil {
+		return err
+	}
+	it, err := c.read(args[0], kindList)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return []string{}
+	}
+	from, to, ok := normRange(start, stop, len(it.list))
+	if !ok {
+		return []string{}
+	}
+	return append([]string{}, it.list[from:to]...)
+}
+
+func cmdLIndex(c *cmdContext, args []string) interface{} {
+	i, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	it, err := c.read(args[0], kindList)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return nil
+	}
+	if i < 0 {
+		i += int64(len(it.list))
+	}
+	if i < 0 || i >= int64(len(it.list)) {
+		return nil
+	}
+	return it.list[i]
+}
+
+// -----------------------------------------------------------------------------
+// sets
+// -----------------------------------------------------------------------------
+
+func sortedMembers(set map[string]struct{}) []string {
+	members := make([]string, 0, len(set))
+	for m := range set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func cmdSAdd(c *cmdContext, args []string) interface{} {
+	it, err := c.write(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, m := range args[1:] {
+		if _, ok := it.set[m]; !ok {
+			it.set[m] = struct{}{}
+			n++
+		}
+	}
+	return n
+}
+
+func cmdSRem(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	n := 0
+	for _, m := range args[1:] {
+		if _, ok := it.set[m]; ok {
+			delete(it.set, m)
+			n++
+		}
+	}
+	if n > 0 {
+		c.modified(args[0])
+	}
+	return n
+}
+
+func cmdSMembers(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return []string{}
+	}
+	return sortedMembers(it.set)
+}
+
+func cmdSCard(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	return len(it.set)
+}
+
+func cmdSIsMember(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	_, ok := it.set[args[1]]
+	return ok
+}
+
+func cmdSRandMember(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	var members []string
+	if it != nil {
+		members = sortedMembers(it.set)
+	}
+	if len(args) == 1 {
+		if len(members) == 0 {
+			return nil
+		}
+		return members[rand.Intn(len(members))]
+	}
+
+	count, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	result := []string{}
+	if len(members) == 0 {
+		return result
+	}
+	if count < 0 {
+		// repeated members allowed
+		for i := int64(0); i < -count; i++ {
+			result = append(result, members[rand.Intn(len(members))])
+		}
+		return result
+	}
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if count < int64(len(members)) {
+		members = members[:count]
+	}
+	return members
+}
+
+func cmdSPop(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	count := int64(1)
+	if len(args) > 1 {
+		if count, err = parseInt(args[1]); err != nil || count < 0 {
+			return errReply("ERR value is out of range, must be positive")
+		}
+	}
+	if it == nil || len(it.set) == 0 {
+		if len(args) > 1 {
+			return []string{}
+		}
+		return nil
+	}
+
+	members := sortedMembers(it.set)
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if count < int64(len(members)) {
+		members = members[:count]
+	}
+	for _, m := range members {
+		delete(it.set, m)
+	}
+	c.modified(args[0])
+	if len(args) == 1 {
+		return members[0]
+	}
+	return members
+}
+
+func cmdSMove(c *cmdContext, args []string) interface{} {
+	src, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	if _, err := c.read(args[1], kindSet); err != nil {
+		return err
+	}
+	if src == nil {
+		return 0
+	}
+	if _, ok := src.set[args[2]]; !ok {
+		return 0
+	}
+	delete(src.set, args[2])
+	c.modified(args[0])
+	dst, _ := c.write(args[1], kindSet)
+	dst.set[args[2]] = struct{}{}
+	return 1
+}
+
+// setAlgebra SUNION/SINTER/SDIFF of keys
+func (c *cmdContext) setAlgebra(op string, keys []string) (map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, 0, len(keys))
+	for _, key := range keys {
+		it, err := c.read(key, kindSet)
+		if err != nil {
+			return nil, err
+		}
+		if it == nil {
+			sets = append(sets, map[string]struct{}{})
+			continue
+		}
+		sets = append(sets, it.set)
+	}
+
+	result := map[string]struct{}{}
+	switch op {
+	case "union":
+		for _, set := range sets {
+			for m := range set {
+				result[m] = struct{}{}
+			}
+		}
+	case "inter":
+	outer:
+		for m := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[m]; !ok {
+					continue outer
+				}
+			}
+			result[m] = struct{}{}
+		}
+	case "diff":
+		for m := range sets[0] {
+			result[m] = struct{}{}
+		}
+		for _, set := range sets[1:] {
+			for m := range set {
+				delete(result, m)
+			}
+		}
+	}
+	return result, nil
+}
+
+func cmdSetAlgebra(c *cmdContext, args []string) interface{} {
+	result, err := c.setAlgebra(strings.TrimPrefix(c.name, "s"), args)
+	if err != nil {
+		return err
+	}
+	return sortedMembers(result)
+}
+
+func cmdSetAlgebraStore(c *cmdContext, args []string) interface{} {
+	op := strings.TrimSuffix(strings.TrimPrefix(c.name, "s"), "store")
+	result, err := c.setAlgebra(op, args[1:])
+	if err != nil {
+		return err
+	}
+	c.del(args[0])
+	if len(result) == 0 {
+		return 0
+	}
+	it, _ := c.write(args[0], kindSet)
+	it.set = result
+	return len(result)
+}
+
+func cmdSScan(c *cmdContext, args []string) interface{} {
+	sa, err := parseScanArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	it, err := c.read(args[0], kindSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return []interface{}{"0", []string{}}
+	}
+	return sa.scan(sortedMembers(it.set), nil)
+}
+
+// -----------------------------------------------------------------------------
+// hashes
+// -----------------------------------------------------------------------------
+
+func cmdHSet(c *cmdContext, args []string) interface{} {
+	if len(args)%2 != 1 {
+		return errArgs(c.name)
+	}
+	it, err := c.write(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for i := 1; i < len(args); i += 2 {
+		if _, ok := it.hash[args[i]]; !ok {
+			n++
+		}
+		it.hash[args[i]] = args[i+1]
+	}
+	if c.name == "hmset" {
+		return okReply
+	}
+	return n
+}
+
+func cmdHSetNX(c *cmdContext, args []string) interface{} {
+	it, err := c.write(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	if _, ok := it.hash[args[1]]; ok {
+		return 0
+	}
+	it.hash[args[1]] = args[2]
+	return 1
+}
+
+func cmdHGet(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return nil
+	}
+	v, ok := it.hash[args[1]]
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+func cmdHMGet(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, 0, len(args)-1)
+	for _, f := range args[1:] {
+		if it == nil {
+			values = append(values, nil)
+			continue
+		}
+		if v, ok := it.hash[f]; ok {
+			values = append(values, v)
+		} else {
+			values = append(values, nil)
+		}
+	}
+	return values
+}
+
+func (it *item) sortedFields() []string {
+	fields := make([]string, 0, len(it.hash))
+	for f := range it.hash {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func cmdHGetAll(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	result := []string{}
+	if it == nil {
+		return result
+	}
+	for _, f := range it.sortedFields() {
+		result = append(result, f, it.hash[f])
+	}
+	return result
+}
+
+func cmdHDel(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	n := 0
+	for _, f := range args[1:] {
+		if _, ok := it.hash[f]; ok {
+			delete(it.hash, f)
+			n++
+		}
+	}
+	if n > 0 {
+		c.modified(args[0])
+	}
+	return n
+}
+
+func cmdHKeys(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return []string{}
+	}
+	return it.sortedFields()
+}
+
+func cmdHVals(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	values := []string{}
+	if it == nil {
+		return values
+	}
+	for _, f := range it.sortedFields() {
+		values = append(values, it.hash[f])
+	}
+	return values
+}
+
+func cmdHLen(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	return len(it.hash)
+}
+
+func cmdHExists(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	_, ok := it.hash[args[1]]
+	return ok
+}
+
+func cmdHIncrBy(c *cmdContext, args []string) interface{} {
+	delta, err := parseInt(args[2])
+	if err != nil {
+		return err
+	}
+	it, err := c.write(args[0], kindHash)
+	if err != nil {
+		return err
+	}
+	var v int64
+	if s, ok := it.hash[args[1]]; ok {
+		if v, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return errReply("ERR hash value is not an integer")
+		}
+	}
+	v += delta
+	it.hash[args[1]] = strconv.FormatInt(v, 10)
+	return v
+}
+
+// -----------------------------------------------------------------------------
+// sorted sets
+// -----------------------------------------------------------------------------
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+// sortedZSet members ordered by score then member
+func (it *item) sortedZSet() []zmember {
+	members := make([]zmember, 0, len(it.zset))
+	for m, s := range it.zset {
+		members = append(members, zmember{m, s})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].member < members[j].member
+	})
+	return members
+}
+
+func reverseZMembers(members []zmember) {
+	for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+		members[i], members[j] = members[j], members[i]
+	}
+}
+
+func zmembersReply(members []zmember, withScores bool) []string {
+	result := make([]string, 0, len(members))
+	for _, m := range members {
+		result = append(result, m.member)
+		if withScores {
+			result = append(result, formatFloat(m.score))
+		}
+	}
+	return result
+}
+
+// ZADD key [NX|XX] [CH] [INCR] score member [score member ...]
+func cmdZAdd(c *cmdContext, args []string) interface{} {
+	key := args[0]
+	var nx, xx, ch, incr bool
+	i := 1
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ch":
+			ch = true
+		case "incr":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (incr && len(pairs) != 2) {
+		return errSyntax
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		f, err := parseFloat(pairs[j])
+		if err != nil {
+			return err
+		}
+		scores = append(scores, f)
+	}
+
+	it, err := c.write(key, kindZSet)
+	if err != nil {
+		return err
+	}
+	added, changed := 0, 0
+	for j := 0; j < len(pairs); j += 2 {
+		score, member := scores[j/2], pairs[j+1]
+		old, exists := it.zset[member]
+		if (nx && exists) || (xx && !exists) {
+			if incr {
+				return nil
+			}
+			continue
+		}
+		if incr {
+			score += old
+		}
+		if !exists {
+			added++
+		} else if old != score {
+			changed++
+		}
+		it.zset[member] = score
+		if incr {
+			return formatFloat(score)
+		}
+	}
+	if ch {
+		return added + changed
+	}
+	return added
+}
+
+func cmdZRem(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	n := 0
+	for _, m := range args[1:] {
+		if _, ok := it.zset[m]; ok {
+			delete(it.zset, m)
+			n++
+		}
+	}
+	if n > 0 {
+		c.modified(args[0])
+	}
+	return n
+}
+
+func cmdZScore(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return nil
+	}
+	score, ok := it.zset[args[1]]
+	if !ok {
+		return nil
+	}
+	return formatFloat(score)
+}
+
+func cmdZCard(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	return len(it.zset)
+}
+
+func cmdZCount(c *cmdContext, args []string) interface{} {
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		return err
+	}
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	n := 0
+	if it != nil {
+		for _, score := range it.zset {
+			if r.contains(score) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func cmdZIncrBy(c *cmdContext, args []string) interface{} {
+	return cmdZAdd(c, []string{args[0], "incr", args[1], args[2]})
+}
+
+func cmdZRank(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return nil
+	}
+	members := it.sortedZSet()
+	if c.name == "zrevrank" {
+		reverseZMembers(members)
+	}
+	for i, m := range members {
+		if m.member == args[1] {
+			return i
+		}
+	}
+	return nil
+}
+
+// ZRANGE key start stop [WITHSCORES]
+func cmdZRange(c *cmdContext, args []string) interface{} {
+	start, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	stop, err := parseInt(args[2])
+	if err != nil {
+		return err
+	}
+	withScores := false
+	if len(args) > 3 {
+		if len(args) != 4 || strings.ToLower(args[3]) != "withscores" {
+			return errSyntax
+		}
+		withScores = true
+	}
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return []string{}
+	}
+	members := it.sortedZSet()
+	if c.name == "zrevrange" {
+		reverseZMembers(members)
+	}
+	from, to, ok := normRange(start, stop, len(members))
+	if !ok {
+		return []string{}
+	}
+	return zmembersReply(members[from:to], withScores)
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func cmdZRangeByScore(c *cmdContext, args []string) interface{} {
+	min, max := args[1], args[2]
+	if c.name == "zrevrangebyscore" {
+		min, max = max, min
+	}
+	r, err := parseScoreRange(min, max)
+	if err != nil {
+		return err
+	}
+	var (
+		withScores    bool
+		offset, count int64 = 0, -1
+	)
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			if offset, err = parseInt(args[i+1]); err != nil {
+				return err
+			}
+			if count, err = parseInt(args[i+2]); err != nil {
+				return err
+			}
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return []string{}
+	}
+	members := it.sortedZSet()
+	if c.name == "zrevrangebyscore" {
+		reverseZMembers(members)
+	}
+	matched := []zmember{}
+	for _, m := range members {
+		if r.contains(m.score) {
+			matched = append(matched, m)
+		}
+	}
+	if offset < 0 || offset >= int64(len(matched)) {
+		return []string{}
+	}
+	matched = matched[offset:]
+	if count >= 0 && count < int64(len(matched)) {
+		matched = matched[:count]
+	}
+	return zmembersReply(matched, withScores)
+}
+
+func cmdZRemRangeByScore(c *cmdContext, args []string) interface{} {
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		return err
+	}
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return 0
+	}
+	n := 0
+	for m, score := range it.zset {
+		if r.contains(score) {
+			delete(it.zset, m)
+			n++
+		}
+	}
+	if n > 0 {
+		c.modified(args[0])
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+// scripting
+// -----------------------------------------------------------------------------
+
+// EVAL script numkeys key [key ...] arg [arg ...]
+func cmdEval(c *cmdContext, args []string) interface{} {
+	src := args[0]
+	if c.name == "evalsha" {
+		var ok bool
+		if src, ok = c.srv.shas[strings.ToLower(args[0])]; !ok {
+			return errReply("NOSCRIPT No matching script. Please use EVAL.")
+		}
+	}
+	fn, ok := c.srv.scripts[src]
+	if !ok {
+		return errReply("ERR cachetest: script not registered, see RegisterScript")
+	}
+
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 0 {
+		return errReply("ERR value is not an integer or out of range")
+	}
+	if numKeys > len(args)-2 {
+		return errReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys, argv := args[2:2+numKeys], args[2+numKeys:]
+	return toReply(fn(c.callFunc(), keys, argv))
+}
+
+// SCRIPT LOAD script | SCRIPT EXISTS sha [sha ...] | SCRIPT FLUSH
+func cmdScript(c *cmdContext, args []string) interface{} {
+	switch strings.ToLower(args[0]) {
+	case "load":
+		if len(args) != 2 {
+			return errArgs("script")
+		}
+		if _, ok := c.srv.scripts[args[1]]; !ok {
+			return errReply("ERR cachetest: script not registered, see RegisterScript")
+		}
+		return scriptSHA(args[1])
+	case "exists":
+		result := make([]interface{}, 0, len(args)-1)
+		for _, sha := range args[1:] {
+			_, ok := c.srv.shas[strings.ToLower(sha)]
+			result = append(result, ok)
+		}
+		return result
+	case "flush":
+		return okReply
+	}
+	return errSyntax
+}
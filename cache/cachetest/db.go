@@ -0,0 +1,430 @@
+package cachetest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// keyspace
+// -----------------------------------------------------------------------------
+
+const (
+	kindString = "string"
+	kindList   = "list"
+	kindSet    = "set"
+	kindHash   = "hash"
+	kindZSet   = "zset"
+)
+
+type item struct {
+	kind     string
+	str      string
+	list     []string
+	set      map[string]struct{}
+	hash     map[string]string
+	zset     map[string]float64
+	expireAt time.Time // zero for no expiration
+}
+
+func newItem(kind string) *item {
+	it := &item{kind: kind}
+	switch kind {
+	case kindSet:
+		it.set = map[string]struct{}{}
+	case kindHash:
+		it.hash = map[string]string{}
+	case kindZSet:
+		it.zset = map[string]float64{}
+	}
+	return it
+}
+
+// empty collection removed like redis
+func (it *item) empty() bool {
+	switch it.kind {
+	case kindList:
+		return len(it.list) == 0
+	case kindSet:
+		return len(it.set) == 0
+	case kindHash:
+		return len(it.hash) == 0
+	case kindZSet:
+		return len(it.zset) == 0
+	}
+	return false
+}
+
+type db struct {
+	items    map[string]*item
+	versions map[string]uint64 // modification version for WATCH
+}
+
+func newDB() *db {
+	return &db{items: map[string]*item{}, versions: map[string]uint64{}}
+}
+
+func (d *db) touch(key string) {
+	d.versions[key]++
+}
+
+// expire remove key if expired
+func (d *db) expire(key string, now time.Time) {
+	if it, ok := d.items[key]; ok && !it.expireAt.IsZero() && !now.Before(it.expireAt) {
+		delete(d.items, key)
+		d.touch(key)
+	}
+}
+
+func (d *db) flush() {
+	for key := range d.items {
+		d.touch(key)
+	}
+	d.items = map[string]*item{}
+}
+
+// keys alive keys sorted
+func (d *db) keys(now time.Time) []string {
+	keys := make([]string, 0, len(d.items))
+	for key := range d.items {
+		d.expire(key, now)
+		if _, ok := d.items[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// -----------------------------------------------------------------------------
+// command context
+// -----------------------------------------------------------------------------
+
+type cmdContext struct {
+	name  string // current command name, lower case
+	srv   *Server
+	db    *db
+	now   time.Time
+	dirty map[string]struct{}
+}
+
+// exec execute a command, must hold lock
+func (s *Server) exec(dbIndex int, args []string) interface{} {
+	c := &cmdContext{srv: s, db: s.db(dbIndex), now: s.now(), dirty: map[string]struct{}{}}
+	reply := c.call(args)
+	c.cleanup()
+	return reply
+}
+
+func (c *cmdContext) call(args []string) interface{} {
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return errUnknown(args[0])
+	}
+	c.name = name
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		return errArgs(name)
+	}
+	return cmd.fn(c, args[1:])
+}
+
+// cleanup remove empty collections
+func (c *cmdContext) cleanup() {
+	for key := range c.dirty {
+		if it, ok := c.db.items[key]; ok && it.empty() {
+			delete(c.db.items, key)
+		}
+	}
+}
+
+// lookup alive item, nil if not exist
+func (c *cmdContext) lookup(key string) *item {
+	c.db.expire(key, c.now)
+	return c.db.items[key]
+}
+
+// read alive item of kind, nil if not exist
+func (c *cmdContext) read(key string, kind string) (*item, error) {
+	it := c.lookup(key)
+	if it != nil && it.kind != kind {
+		return nil, errWrongType
+	}
+	return it, nil
+}
+
+// write item of kind for modification, created if not exist
+func (c *cmdContext) write(key string, kind string) (*item, error) {
+	it, err := c.read(key, kind)
+	if err != nil {
+		return nil, err
+	}
+	if it == nil {
+		it = newItem(kind)
+		c.db.items[key] = it
+	}
+	c.modified(key)
+	return it, nil
+}
+
+// modified mark key modified
+func (c *cmdContext) modified(key string) {
+	c.db.touch(key)
+	c.dirty[key] = struct{}{}
+}
+
+func (c *cmdContext) del(key string) bool {
+	if c.lookup(key) == nil {
+		return false
+	}
+	delete(c.db.items, key)
+	c.modified(key)
+	return true
+}
+
+// set string value, clear expiration
+func (c *cmdContext) setString(key string, value string) {
+	it := newItem(kindString)
+	it.str = value
+	c.db.items[key] = it
+	c.modified(key)
+}
+
+// -----------------------------------------------------------------------------
+// helpers
+// -----------------------------------------------------------------------------
+
+var (
+	errWrongType = errReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInt    = errReply("ERR value is not an integer or out of range")
+	errNotFloat  = errReply("ERR value is not a valid float")
+	errSyntax    = errReply("ERR syntax error")
+)
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errNotInt
+	}
+	return n, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// scoreRange min/max of ZCOUNT/ZRANGEBYSCORE, "(" prefix for exclusive
+type scoreRange struct {
+	min, max     float64
+	minEx, maxEx bool
+}
+
+func parseScoreRange(min string, max string) (r scoreRange, err error) {
+	if strings.HasPrefix(min, "(") {
+		r.minEx, min = true, min[1:]
+	}
+	if strings.HasPrefix(max, "(") {
+		r.maxEx, max = true, max[1:]
+	}
+	if r.min, err = parseFloat(min); err != nil {
+		return r, errReply("ERR min or max is not a float")
+	}
+	if r.max, err = parseFloat(max); err != nil {
+		return r, errReply("ERR min or max is not a float")
+	}
+	return r, nil
+}
+
+func (r scoreRange) contains(f float64) bool {
+	if f < r.min || (r.minEx && f == r.min) {
+		return false
+	}
+	if f > r.max || (r.maxEx && f == r.max) {
+		return false
+	}
+	return true
+}
+
+// normRange LRANGE/ZRANGE like index range to [start, stop), ok false if empty
+func normRange(start int64, stop int64, n int) (int, int, bool) {
+	size := int64(n)
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || start >= size {
+		return 0, 0, false
+	}
+	return int(start), int(stop + 1), true
+}
+
+// globMatch redis glob-style pattern: * ? [abc] [^a] [a-z] \x
+func globMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// no closing bracket, match literally
+				if s[0] != '[' {
+					return false
+				}
+				s, pattern = s[1:], pattern[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			pattern = pattern[end+2:]
+			not := false
+			if strings.HasPrefix(class, "^") {
+				not, class = true, class[1:]
+			}
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if class[i] == '\\' && i+1 < len(class) {
+					i++
+					if class[i] == s[0] {
+						matched = true
+					}
+				} else if i+2 < len(class) && class[i+1] == '-' {
+					lo, hi := class[i], class[i+2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					if s[0] >= lo && s[0] <= hi {
+						matched = true
+					}
+					i += 2
+				} else if class[i] == s[0] {
+					matched = true
+				}
+			}
+			if matched == not {
+				return false
+			}
+			s = s[1:]
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// scanArgs cursor [MATCH pattern] [COUNT count] [TYPE type]
+type scanArgs struct {
+	cursor  int
+	match   string
+	count   int
+	typ     string
+	invalid bool
+}
+
+func parseScanArgs(args []string) (scanArgs, error) {
+	sa := scanArgs{match: "*", count: 10}
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return sa, errReply("ERR invalid cursor")
+	}
+	sa.cursor = cursor
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return sa, errSyntax
+		}
+		switch strings.ToLower(args[i]) {
+		case "match":
+			sa.match = args[i+1]
+		case "count":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				return sa, errSyntax
+			}
+			sa.count = n
+		case "type":
+			sa.typ = strings.ToLower(args[i+1])
+		default:
+			return sa, errSyntax
+		}
+	}
+	return sa, nil
+}
+
+// scan page of sorted elements, cursor is offset
+func (sa scanArgs) scan(elements []string, filter func(string) bool) []interface{} {
+	matched := []string{}
+	end := sa.cursor + sa.count
+	if end > len(elements) {
+		end = len(elements)
+	}
+	for i := sa.cursor; i < end; i++ {
+		e := elements[i]
+		if globMatch(sa.match, e) && (filter == nil || filter(e)) {
+			matched = append(matched, e)
+		}
+	}
+	next := end
+	if next >= len(elements) {
+		next = 0
+	}
+	return []interface{}{strconv.Itoa(next), matched}
+}
@@ -0,0 +1,281 @@
+package cachetest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// geo commands
+// members stored in sorted set scored by 52 bits geohash like redis.
+// -----------------------------------------------------------------------------
+
+const (
+	geoStep      = 26
+	geoLatMin    = -85.05112878
+	geoLatMax    = 85.05112878
+	geoLonMin    = -180.0
+	geoLonMax    = 180.0
+	earthRadiusM = 6372797.560856
+)
+
+// interleave x in even bits, y in odd bits
+func interleave(x uint32, y uint32) uint64 {
+	var v uint64
+	for i := uint(0); i < geoStep; i++ {
+		v |= uint64(x>>i&1) << (2 * i)
+		v |= uint64(y>>i&1) << (2*i + 1)
+	}
+	return v
+}
+
+func deinterleave(v uint64) (uint32, uint32) {
+	var x, y uint32
+	for i := uint(0); i < geoStep; i++ {
+		x |= uint32(v>>(2*i)&1) << i
+		y |= uint32(v>>(2*i+1)&1) << i
+	}
+	return x, y
+}
+
+func geoEncode(lon float64, lat float64) float64 {
+	latOffset := (lat - geoLatMin) / (geoLatMax - geoLatMin) * (1 << geoStep)
+	lonOffset := (lon - geoLonMin) / (geoLonMax - geoLonMin) * (1 << geoStep)
+	return float64(interleave(uint32(latOffset), uint32(lonOffset)))
+}
+
+// geoDecode center of the geohash cell
+func geoDecode(score float64) (float64, float64) {
+	latOffset, lonOffset := deinterleave(uint64(score))
+	latStep := (geoLatMax - geoLatMin) / (1 << geoStep)
+	lonStep := (geoLonMax - geoLonMin) / (1 << geoStep)
+	lat := geoLatMin + (float64(latOffset)+0.5)*latStep
+	lon := geoLonMin + (float64(lonOffset)+0.5)*lonStep
+	return math.Max(geoLonMin, math.Min(geoLonMax, lon)), math.Max(geoLatMin, math.Min(geoLatMax, lat))
+}
+
+// geoDistance haversine distance in meters
+func geoDistance(lon1 float64, lat1 float64, lon2 float64, lat2 float64) float64 {
+	rad := math.Pi / 180
+	lat1r, lat2r := lat1*rad, lat2*rad
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2 - lon1) * rad / 2)
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+func geoUnit(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, errReply("ERR unsupported unit provided. please use m, km, ft, mi")
+}
+
+func formatDist(d float64) string {
+	return strconv.FormatFloat(d, 'f', 4, 64)
+}
+
+// GEOADD key longitude latitude member [longitude latitude member ...]
+func cmdGeoAdd(c *cmdContext, args []string) interface{} {
+	if (len(args)-1)%3 != 0 {
+		return errSyntax
+	}
+	zargs := []string{args[0]}
+	for i := 1; i < len(args); i += 3 {
+		lon, err := parseFloat(args[i])
+		if err != nil {
+			return err
+		}
+		lat, err := parseFloat(args[i+1])
+		if err != nil {
+			return err
+		}
+		if lon < geoLonMin || lon > geoLonMax || lat < geoLatMin || lat > geoLatMax {
+			return errReply("ERR invalid longitude,latitude pair " + args[i] + "," + args[i+1])
+		}
+		zargs = append(zargs, strconv.FormatFloat(geoEncode(lon, lat), 'f', -1, 64), args[i+2])
+	}
+	return cmdZAdd(c, zargs)
+}
+
+func cmdGeoPos(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	result := make([]interface{}, 0, len(args)-1)
+	for _, m := range args[1:] {
+		if it == nil {
+			result = append(result, nilArray)
+			continue
+		}
+		score, ok := it.zset[m]
+		if !ok {
+			result = append(result, nilArray)
+			continue
+		}
+		lon, lat := geoDecode(score)
+		result = append(result, []interface{}{formatFloat(lon), formatFloat(lat)})
+	}
+	return result
+}
+
+// GEODIST key member1 member2 [unit]
+func cmdGeoDist(c *cmdContext, args []string) interface{} {
+	unit := 1.0
+	if len(args) > 3 {
+		u, err := geoUnit(args[3])
+		if err != nil {
+			return err
+		}
+		unit = u
+	}
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return nil
+	}
+	s1, ok1 := it.zset[args[1]]
+	s2, ok2 := it.zset[args[2]]
+	if !ok1 || !ok2 {
+		return nil
+	}
+	lon1, lat1 := geoDecode(s1)
+	lon2, lat2 := geoDecode(s2)
+	return formatDist(geoDistance(lon1, lat1, lon2, lat2) / unit)
+}
+
+type geoMatch struct {
+	member   string
+	dist     float64
+	lon, lat float64
+	score    float64
+}
+
+// GEORADIUS key longitude latitude radius unit [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count] [ASC|DESC]
+// GEORADIUSBYMEMBER key member radius unit [...]
+func cmdGeoRadius(c *cmdContext, args []string) interface{} {
+	it, err := c.read(args[0], kindZSet)
+	if err != nil {
+		return err
+	}
+
+	var lon, lat float64
+	rest := args[1:]
+	if strings.HasPrefix(c.name, "georadiusbymember") {
+		if it == nil {
+			return errReply("ERR could not decode requested zset member")
+		}
+		score, ok := it.zset[rest[0]]
+		if !ok {
+			return errReply("ERR could not decode requested zset member")
+		}
+		lon, lat = geoDecode(score)
+		rest = rest[1:]
+	} else {
+		if len(rest) < 4 {
+			return errArgs(c.name)
+		}
+		if lon, err = parseFloat(rest[0]); err != nil {
+			return err
+		}
+		if lat, err = parseFloat(rest[1]); err != nil {
+			return err
+		}
+		rest = rest[2:]
+	}
+
+	radius, err := parseFloat(rest[0])
+	if err != nil || radius < 0 {
+		return errReply("ERR radius cannot be negative")
+	}
+	unit, err := geoUnit(rest[1])
+	if err != nil {
+		return err
+	}
+
+	var (
+		withCoord, withDist, withHash bool
+		count                         int64
+		order                         string
+	)
+	for i := 2; i < len(rest); i++ {
+		switch strings.ToLower(rest[i]) {
+		case "withcoord":
+			withCoord = true
+		case "withdist":
+			withDist = true
+		case "withhash":
+			withHash = true
+		case "asc", "desc":
+			order = strings.ToLower(rest[i])
+		case "count":
+			if i+1 >= len(rest) {
+				return errSyntax
+			}
+			if count, err = parseInt(rest[i+1]); err != nil || count <= 0 {
+				return errReply("ERR COUNT must be > 0")
+			}
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	matches := []geoMatch{}
+	if it != nil {
+		for m, score := range it.zset {
+			mlon, mlat := geoDecode(score)
+			d := geoDistance(lon, lat, mlon, mlat)
+			if d <= radius*unit {
+				matches = append(matches, geoMatch{m, d / unit, mlon, mlat, score})
+			}
+		}
+	}
+	// redis sort ASC when COUNT without order
+	if order == "" && count > 0 {
+		order = "asc"
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if order == "desc" {
+			return matches[i].dist > matches[j].dist
+		}
+		if order == "asc" {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].member < matches[j].member
+	})
+	if count > 0 && count < int64(len(matches)) {
+		matches = matches[:count]
+	}
+
+	result := make([]interface{}, 0, len(matches))
+	for _, m := range matches {
+		if !withCoord && !withDist && !withHash {
+			result = append(result, m.member)
+			continue
+		}
+		entry := []interface{}{m.member}
+		if withDist {
+			entry = append(entry, formatDist(m.dist))
+		}
+		if withHash {
+			entry = append(entry, int64(m.score))
+		}
+		if withCoord {
+			entry = append(entry, []interface{}{formatFloat(m.lon), formatFloat(m.lat)})
+		}
+		result = append(result, entry)
+	}
+	return result
+}
@@ -0,0 +1,215 @@
+package cachetest
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
+)
+
+// CallFunc run a command in script like `redis.call`, reply is one of:
+// nil (nil bulk/array), int64, string, []interface{}, error (redis error reply)
+type CallFunc func(args ...interface{}) interface{}
+
+// ScriptFunc go emulation of a lua script, return value converted to reply like lua:
+// nil (lua false), int64/int/bool, string, []interface{}, error
+type ScriptFunc func(call CallFunc, keys []string, argv []string) interface{}
+
+// callFunc run commands in current context (atomic with the script)
+func (c *cmdContext) callFunc() CallFunc {
+	return func(args ...interface{}) interface{} {
+		strs := make([]string, 0, len(args))
+		for _, arg := range args {
+			strs = append(strs, fmt.Sprint(arg))
+		}
+		return fromReply(c.call(strs))
+	}
+}
+
+// fromReply command reply to value for script
+func fromReply(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nilArrayReply:
+		return nil
+	case statusReply:
+		return string(v)
+	case errReply:
+		return error(v)
+	case int:
+		return int64(v)
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return int64(0)
+	case []string:
+		result := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			result = append(result, e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			result = append(result, fromReply(e))
+		}
+		return result
+	}
+	return v
+}
+
+// toReply script result to command reply
+func toReply(v interface{}) interface{} {
+	switch v := v.(type) {
+	case errReply:
+		return v
+	case error:
+		return errReply(v.Error())
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			result = append(result, toReply(e))
+		}
+		return result
+	}
+	return v
+}
+
+// toNumber like lua tonumber, nil (false) and invalid number as 0
+func toNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+// builtinScripts emulations of package cache scripts, see package cache/internal/scripts
+var builtinScripts = map[string]ScriptFunc{
+	scripts.IdemReserve: func(call CallFunc, keys []string, argv []string) interface{} {
+		v := call("GET", keys[0])
+		if v == nil {
+			call("SET", keys[0], argv[0], "PX", argv[1])
+			return ""
+		}
+		return v
+	},
+
+	scripts.RenewLock: func(call CallFunc, keys []string, argv []string) interface{} {
+		if call("GET", keys[0]) == argv[0] {
+			return call("PEXPIRE", keys[0], argv[1])
+		}
+		return 0
+	},
+
+	scripts.CounterDecrMinZero: func(call CallFunc, keys []string, argv []string) interface{} {
+		v := call("GET", keys[0])
+		if v == nil {
+			return -2
+		}
+		if toNumber(v) > 0 {
+			return call("DECR", keys[0])
+		}
+		return -1
+	},
+
+	scripts.SemAcquire: func(call CallFunc, keys []string, argv []string) interface{} {
+		call("ZREMRANGEBYSCORE", keys[0], "-inf", argv[0])
+		if call("ZSCORE", keys[0], argv[2]) == nil {
+			if toNumber(call("ZCARD", keys[0])) >= toNumber(argv[1]) {
+				return 0
+			}
+		}
+		call("ZADD", keys[0], argv[3], argv[2])
+		if toNumber(call("PTTL", keys[0])) < toNumber(argv[4]) {
+			call("PEXPIRE", keys[0], argv[4])
+		}
+		return 1
+	},
+
+	scripts.SemRenew: func(call CallFunc, keys []string, argv []string) interface{} {
+		score := call("ZSCORE", keys[0], argv[1])
+		if score == nil || toNumber(score) <= toNumber(argv[0]) {
+			return 0
+		}
+		call("ZADD", keys[0], argv[2], argv[1])
+		if toNumber(call("PTTL", keys[0])) < toNumber(argv[3]) {
+			call("PEXPIRE", keys[0], argv[3])
+		}
+		return 1
+	},
+
+	scripts.VCodeIssue: func(call CallFunc, keys []string, argv []string) interface{} {
+		if call("EXISTS", keys[1]) == int64(1) {
+			return -1
+		}
+		quota := toNumber(argv[4])
+		if quota > 0 && toNumber(call("GET", keys[2])) >= quota {
+			return -2
+		}
+		call("DEL", keys[0])
+		call("HSET", keys[0], "code", argv[0], "attempts", argv[3])
+		call("PEXPIRE", keys[0], argv[1])
+		if toNumber(argv[2]) > 0 {
+			call("SET", keys[1], "1", "PX", argv[2])
+		}
+		if quota > 0 {
+			call("INCR", keys[2])
+			call("PEXPIREAT", keys[2], argv[5])
+		}
+		return 0
+	},
+
+	scripts.VCodeVerify: func(call CallFunc, keys []string, argv []string) interface{} {
+		code := call("HGET", keys[0], "code")
+		if code == nil {
+			return -1
+		}
+		if code == argv[0] {
+			call("DEL", keys[0])
+			return 1
+		}
+		if toNumber(call("HINCRBY", keys[0], "attempts", -1)) <= 0 {
+			call("DEL", keys[0])
+		}
+		return 0
+	},
+
+	scripts.VCodeRevoke: func(call CallFunc, keys []string, argv []string) interface{} {
+		call("DEL", keys[0], keys[1])
+		if toNumber(call("GET", keys[2])) > 0 {
+			call("DECR", keys[2])
+		}
+		return 1
+	},
+
+	scripts.WindowIncr: func(call CallFunc, keys []string, argv []string) interface{} {
+		if toNumber(argv[0]) < toNumber(argv[2]) {
+			return 0
+		}
+		v := call("HINCRBY", keys[0], argv[0], argv[1])
+		gc := toNumber(call("HGET", keys[0], "_gc"))
+		if gc < toNumber(argv[0]) {
+			oldest := toNumber(argv[2])
+			fields, _ := call("HKEYS", keys[0]).([]interface{})
+			for _, f := range fields {
+				if f != "_gc" && toNumber(f) < oldest {
+					call("HDEL", keys[0], f)
+				}
+			}
+			call("HSET", keys[0], "_gc", argv[0])
+		}
+		call("PEXPIRE", keys[0], argv[3])
+		return v
+	},
+
+	scripts.SessionSet: func(call CallFunc, keys []string, argv []string) interface{} {
+		if call("EXISTS", keys[0]) == int64(1) {
+			return call("HSET", keys[0], argv[0], argv[1])
+		}
+		return -1
+	},
+}
@@ -0,0 +1,51 @@
+package cachetest
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// every script of package cache must be emulated, parsed from source as constants
+// can't be enumerated at runtime
+func TestBuiltinScriptsCovered(t *testing.T) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), "../internal/scripts", nil, 0)
+	require.Nil(t, err)
+
+	consts := map[string]string{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.CONST {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					vs := spec.(*ast.ValueSpec)
+					for i, name := range vs.Names {
+						if !name.IsExported() {
+							continue
+						}
+						lit, ok := vs.Values[i].(*ast.BasicLit)
+						require.True(t, ok, "script %s must be a string literal", name.Name)
+						src, err := strconv.Unquote(lit.Value)
+						require.Nil(t, err)
+						consts[name.Name] = src
+					}
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, consts)
+
+	for name, src := range consts {
+		_, ok := builtinScripts[src]
+		assert.True(t, ok, "script %s not emulated", name)
+	}
+	assert.Equal(t, len(consts), len(builtinScripts), "emulation of unknown script")
+}
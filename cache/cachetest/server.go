@@ -0,0 +1,474 @@
+// Package cachetest in-process fake redis server speaking RESP, make tests depend on
+// package cache run hermetically (no real redis needed).
+//
+// covered commands: strings/counters, keys & TTL, lists (include BLPOP), sets, hashes,
+// sorted sets, geo, SCAN/SSCAN, MULTI/EXEC/WATCH, and EVAL of the lua scripts used by package
+// cache (emulated in go, keyed by script text, see RegisterScript).
+//
+//	srv, err := cachetest.NewServer()
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer srv.Close()
+//	cache.InitCache("myapp", srv.Addr(), "", 0)
+package cachetest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server fake redis server, all commands executed serially (atomic like redis)
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	dbs     map[int]*db
+	offset  time.Duration // FastForward offset
+	scripts map[string]ScriptFunc
+	shas    map[string]string // sha1 -> script
+	conns   map[net.Conn]struct{}
+	closed  bool
+
+	wg sync.WaitGroup
+}
+
+// NewServer start a fake server listen on random local port, the scripts of package cache registered
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:      ln,
+		dbs:     map[int]*db{},
+		scripts: map[string]ScriptFunc{},
+		shas:    map[string]string{},
+		conns:   map[net.Conn]struct{}{},
+	}
+	for src, fn := range builtinScripts {
+		s.RegisterScript(src, fn)
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr listen address, e.g. "127.0.0.1:50123"
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stop server, close all connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.ln.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// FlushAll remove all keys of all dbs
+func (s *Server) FlushAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.dbs {
+		d.flush()
+	}
+}
+
+// FastForward move server clock forward, make keys expire without sleep
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset += d
+}
+
+// Keys alive keys of db 0, sorted
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db(0).keys(s.now())
+}
+
+// RegisterScript register go emulation of lua script `src`, EVAL/EVALSHA of it run `fn`
+func (s *Server) RegisterScript(src string, fn ScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[src] = fn
+	s.shas[scriptSHA(src)] = src
+}
+
+func scriptSHA(src string) string {
+	sum := sha1.Sum([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+// now must hold lock
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// db must hold lock
+func (s *Server) db(i int) *db {
+	d, ok := s.dbs[i]
+	if !ok {
+		d = newDB()
+		s.dbs[i] = d
+	}
+	return d
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return
+		}
+		s.conns[nc] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			newConn(s, nc).serve()
+			s.mu.Lock()
+			delete(s.conns, nc)
+			s.mu.Unlock()
+			nc.Close()
+		}()
+	}
+}
+
+// -----------------------------------------------------------------------------
+// connection
+// -----------------------------------------------------------------------------
+
+type watchedKey struct {
+	db  int
+	key string
+}
+
+type conn struct {
+	srv *Server
+	nc  net.Conn
+	r   *bufio.Reader
+	w   *bufio.Writer
+
+	db      int
+	multi   bool
+	queued  [][]string
+	watched map[watchedKey]uint64
+}
+
+func newConn(s *Server, nc net.Conn) *conn {
+	return &conn{
+		srv:     s,
+		nc:      nc,
+		r:       bufio.NewReader(nc),
+		w:       bufio.NewWriter(nc),
+		watched: map[watchedKey]uint64{},
+	}
+}
+
+func (c *conn) serve() {
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			if err != io.EOF {
+				writeReply(c.w, errReply("ERR Protocol error: "+err.Error()))
+				c.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := strings.ToLower(args[0]) == "quit"
+		writeReply(c.w, c.handle(args))
+		// flush when no more pipelined commands buffered
+		if c.r.Buffered() == 0 || quit {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+func (c *conn) handle(args []string) interface{} {
+	name := strings.ToLower(args[0])
+	switch name {
+	case "quit":
+		return okReply
+	case "multi":
+		if c.multi {
+			return errReply("ERR MULTI calls can not be nested")
+		}
+		c.multi = true
+		c.queued = nil
+		return okReply
+	case "exec":
+		if !c.multi {
+			return errReply("ERR EXEC without MULTI")
+		}
+		return c.exec()
+	case "discard":
+		if !c.multi {
+			return errReply("ERR DISCARD without MULTI")
+		}
+		c.multi = false
+		c.queued = nil
+		c.watched = map[watchedKey]uint64{}
+		return okReply
+	case "watch":
+		if c.multi {
+			return errReply("ERR WATCH inside MULTI is not allowed")
+		}
+		if len(args) < 2 {
+			return errArgs(name)
+		}
+		c.srv.mu.Lock()
+		d := c.srv.db(c.db)
+		for _, key := range args[1:] {
+			c.watched[watchedKey{c.db, key}] = d.versions[key]
+		}
+		c.srv.mu.Unlock()
+		return okReply
+	case "unwatch":
+		c.watched = map[watchedKey]uint64{}
+		return okReply
+	case "select":
+		if len(args) != 2 {
+			return errArgs(name)
+		}
+		i, err := strconv.Atoi(args[1])
+		if err != nil || i < 0 || i > 15 {
+			return errReply("ERR DB index is out of range")
+		}
+		c.db = i
+		return okReply
+	case "blpop", "brpop":
+		if c.multi {
+			break
+		}
+		return c.blockPop(name, args)
+	}
+
+	if c.multi {
+		if _, ok := commands[name]; !ok {
+			return errUnknown(args[0])
+		}
+		c.queued = append(c.queued, args)
+		return statusReply("QUEUED")
+	}
+
+	c.srv.mu.Lock()
+	defer c.srv.mu.Unlock()
+	return c.srv.exec(c.db, args)
+}
+
+func (c *conn) exec() interface{} {
+	queued := c.queued
+	c.multi = false
+	c.queued = nil
+	watched := c.watched
+	c.watched = map[watchedKey]uint64{}
+
+	c.srv.mu.Lock()
+	defer c.srv.mu.Unlock()
+	for wk, version := range watched {
+		d := c.srv.db(wk.db)
+		d.expire(wk.key, c.srv.now())
+		if d.versions[wk.key] != version {
+			return nilArray
+		}
+	}
+	replies := make([]interface{}, 0, len(queued))
+	for _, args := range queued {
+		replies = append(replies, c.srv.exec(c.db, args))
+	}
+	return replies
+}
+
+// blockPop BLPOP/BRPOP key [key ...] timeout, poll until timeout
+func (c *conn) blockPop(name string, args []string) interface{} {
+	if len(args) < 3 {
+		return errArgs(name)
+	}
+	timeout, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil || timeout < 0 {
+		return errReply("ERR timeout is not a float or out of range")
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(time.Duration(timeout * float64(time.Second)))
+	}
+
+	pop := "lpop"
+	if name == "brpop" {
+		pop = "rpop"
+	}
+	for {
+		c.srv.mu.Lock()
+		if c.srv.closed {
+			c.srv.mu.Unlock()
+			return nilArray
+		}
+		for _, key := range args[1 : len(args)-1] {
+			v := c.srv.exec(c.db, []string{pop, key})
+			if e, ok := v.(errReply); ok {
+				c.srv.mu.Unlock()
+				return e
+			}
+			if v != nil {
+				c.srv.mu.Unlock()
+				return []interface{}{key, v}
+			}
+		}
+		c.srv.mu.Unlock()
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nilArray
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// RESP protocol
+// -----------------------------------------------------------------------------
+
+type statusReply string
+
+type errReply string
+
+func (e errReply) Error() string {
+	return string(e)
+}
+
+type nilArrayReply struct{}
+
+var (
+	okReply  = statusReply("OK")
+	nilArray = nilArrayReply{}
+)
+
+func errArgs(name string) errReply {
+	return errReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+func errUnknown(name string) errReply {
+	return errReply(fmt.Sprintf("ERR unknown command `%s`", name))
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readCommand multi bulk request, or inline command (e.g. from telnet)
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid multibulk length")
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected '$', got '%s'", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case nilArrayReply:
+		w.WriteString("*-1\r\n")
+	case statusReply:
+		w.WriteString("+" + string(v) + "\r\n")
+	case errReply:
+		w.WriteString("-" + string(v) + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case bool:
+		if v {
+			w.WriteString(":1\r\n")
+		} else {
+			w.WriteString(":0\r\n")
+		}
+	case float64:
+		writeReply(w, formatFloat(v))
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []string:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, e := range v {
+			writeReply(w, e)
+		}
+	case []interface{}:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, e := range v {
+			writeReply(w, e)
+		}
+	default:
+		writeReply(w, errReply(fmt.Sprintf("ERR cachetest unsupported reply %T", v)))
+	}
+}
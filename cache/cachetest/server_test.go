@@ -0,0 +1,163 @@
+package cachetest
+
+import (
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Server, *redis.Client) {
+	srv, err := NewServer()
+	require.Nil(t, err)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		srv.Close()
+	})
+	return srv, client
+}
+
+func TestStringsAndTTL(t *testing.T) {
+	srv, c := newTestClient(t)
+
+	require.Nil(t, c.Ping().Err())
+	require.Nil(t, c.Set("a", "1", time.Minute).Err())
+	assert.Equal(t, "1", c.Get("a").Val())
+	assert.Equal(t, redis.Nil, c.Get("none").Err())
+	assert.EqualValues(t, 11, c.IncrBy("a", 10).Val())
+	assert.True(t, c.TTL("a").Val() > 59*time.Second)
+
+	ok, err := c.SetNX("a", "2", 0).Result()
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	// expired by fast forward
+	srv.FastForward(time.Minute)
+	assert.Equal(t, redis.Nil, c.Get("a").Err())
+	assert.EqualValues(t, -2, c.PTTL("a").Val())
+
+	c.Set("b", "x", 0)
+	assert.EqualValues(t, -1, c.TTL("b").Val())
+	assert.NotNil(t, c.Incr("b").Err())
+	assert.Equal(t, "string", c.Type("b").Val())
+	assert.NotNil(t, c.LPush("b", "1").Err()) // wrong type
+	assert.EqualValues(t, 1, c.Unlink("b", "none").Val())
+	assert.Equal(t, []string{}, srv.Keys())
+}
+
+func TestCollections(t *testing.T) {
+	_, c := newTestClient(t)
+
+	c.RPush("l", "1", "2", "3")
+	assert.Equal(t, []string{"2", "3"}, c.LRange("l", 1, -1).Val())
+	assert.Equal(t, "1", c.LPop("l").Val())
+
+	v, err := c.BLPop(time.Second, "empty").Result()
+	assert.Equal(t, redis.Nil, err)
+	assert.Nil(t, v)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.RPush("q", "hello")
+	}()
+	v, err = c.BLPop(time.Second, "q").Result()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"q", "hello"}, v)
+	assert.EqualValues(t, 0, c.Exists("q").Val()) // empty list removed
+
+	c.SAdd("s1", "a", "b", "c")
+	c.SAdd("s2", "b", "c", "d")
+	assert.Equal(t, []string{"b", "c"}, c.SInter("s1", "s2").Val())
+	assert.Equal(t, []string{"a"}, c.SDiff("s1", "s2").Val())
+	assert.EqualValues(t, 4, c.SUnionStore("s3", "s1", "s2").Val())
+
+	c.HSet("h", "f1", "1", "f2", "2")
+	assert.Equal(t, map[string]string{"f1": "1", "f2": "2"}, c.HGetAll("h").Val())
+	assert.EqualValues(t, 11, c.HIncrBy("h", "f1", 10).Val())
+	assert.Equal(t, []interface{}{"11", nil}, c.HMGet("h", "f1", "none").Val())
+
+	c.ZAdd("z", &redis.Z{Score: 1, Member: "a"}, &redis.Z{Score: 2, Member: "b"}, &redis.Z{Score: 3, Member: "c"})
+	assert.EqualValues(t, 2, c.ZCount("z", "(1", "+inf").Val())
+	members := c.ZRangeByScore("z", &redis.ZRangeBy{Min: "-inf", Max: "+inf", Offset: 1, Count: 1}).Val()
+	assert.Equal(t, []string{"b"}, members)
+	assert.EqualValues(t, 2, c.ZRemRangeByScore("z", "-inf", "2").Val())
+	assert.Equal(t, 3.0, c.ZScore("z", "c").Val())
+}
+
+func TestScan(t *testing.T) {
+	_, c := newTestClient(t)
+
+	for _, key := range []string{"app:a", "app:b", "app:_mq_.c", "other"} {
+		c.Set(key, "1", 0)
+	}
+	c.SAdd("app:set", "1")
+
+	keys := []string{}
+	var cursor uint64
+	for {
+		page, next, err := c.Scan(cursor, "app:*", 2).Result()
+		require.Nil(t, err)
+		keys = append(keys, page...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.ElementsMatch(t, []string{"app:a", "app:b", "app:_mq_.c", "app:set"}, keys)
+
+	assert.True(t, globMatch("app:\\_mq\\_.*", "app:_mq_.c"))
+	assert.True(t, globMatch("h[ae]llo", "hallo"))
+	assert.False(t, globMatch("h[^e]llo", "hello"))
+	assert.True(t, globMatch("m[0-9]?", "m12"))
+}
+
+func TestTransaction(t *testing.T) {
+	_, c := newTestClient(t)
+
+	pipe := c.TxPipeline()
+	incr := pipe.Incr("n")
+	pipe.Expire("n", time.Minute)
+	_, err := pipe.Exec()
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, incr.Val())
+
+	// watched key modified by others
+	err = c.Watch(func(tx *redis.Tx) error {
+		c.Incr("n")
+		_, err := tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set("n", "100", 0)
+			return nil
+		})
+		return err
+	}, "n")
+	assert.Equal(t, redis.TxFailedErr, err)
+	assert.Equal(t, "2", c.Get("n").Val())
+
+	err = c.Watch(func(tx *redis.Tx) error {
+		_, err := tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set("n", "100", 0)
+			return nil
+		})
+		return err
+	}, "n")
+	require.Nil(t, err)
+	assert.Equal(t, "100", c.Get("n").Val())
+}
+
+func TestEval(t *testing.T) {
+	srv, c := newTestClient(t)
+
+	script := `return redis.call("INCRBY", KEYS[1], ARGV[1])`
+	assert.NotNil(t, c.Eval(script, []string{"n"}, 1).Err())
+
+	srv.RegisterScript(script, func(call CallFunc, keys []string, argv []string) interface{} {
+		return call("INCRBY", keys[0], argv[0])
+	})
+	assert.EqualValues(t, 2, c.Eval(script, []string{"n"}, 2).Val())
+
+	// EVALSHA
+	s := redis.NewScript(script)
+	assert.EqualValues(t, 5, s.Run(c, []string{"n"}, 3).Val())
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	ErrUnknownCodec      = fmt.Errorf("unknown codec")
+	ErrUnknownCompressor = fmt.Errorf("unknown compressor")
+)
+
+// Codec object serialization used by SetObject/GetObject
+type Codec interface {
+	// ID codec unique id, range [1, 15], written into value header
+	ID() byte
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Compressor value compression, applied when encoded size reach threshold
+type Compressor interface {
+	// ID compressor unique id, range [1, 7], written into value header
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Value header byte layout:
+//
+//	1 ccc nnnn
+//	| |   |
+//	| |   +---- codec id
+//	| +-------- compressor id, 0 for not compressed
+//	+---------- header flag
+//
+// json text never start with a byte >= 0x80, so values written before codec
+// introduced (plain json without header) still readable.
+const (
+	headerFlag     byte = 0x80
+	codecMask      byte = 0x0f
+	compressorMask byte = 0x70
+)
+
+var (
+	JSONCodec   Codec = jsonCodec{}
+	GobCodec    Codec = gobCodec{}
+	BinaryCodec Codec = msgpackCodec{}
+
+	GzipCompressor  Compressor = gzipCompressor{}
+	FlateCompressor Compressor = flateCompressor{}
+
+	codecMu     sync.RWMutex
+	codecs      = map[byte]Codec{}
+	compressors = map[byte]Compressor{}
+
+	objectCodec          Codec      = JSONCodec
+	objectCompressor     Compressor = nil
+	objectCompressAtSize int        = 0
+)
+
+func init() {
+	for _, c := range []Codec{JSONCodec, GobCodec, BinaryCodec} {
+		RegisterCodec(c)
+	}
+	for _, c := range []Compressor{GzipCompressor, FlateCompressor} {
+		RegisterCompressor(c)
+	}
+}
+
+// RegisterCodec register a custom codec, make value written by it readable
+func RegisterCodec(c Codec) {
+	if c.ID() == 0 || c.ID()&codecMask != c.ID() {
+		panic(fmt.Sprintf("cache: codec id %d out of range", c.ID()))
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[c.ID()] = c
+}
+
+// RegisterCompressor register a custom compressor, make value written by it readable
+func RegisterCompressor(c Compressor) {
+	if c.ID() == 0 || (c.ID()<<4)&compressorMask != c.ID()<<4 {
+		panic(fmt.Sprintf("cache: compressor id %d out of range", c.ID()))
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	compressors[c.ID()] = c
+}
+
+// SetObjectCodec codec used by SetObject, default JSONCodec.
+// codec switching is safe, GetObject choose codec by value header.
+func SetObjectCodec(c Codec) {
+	RegisterCodec(c)
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	objectCodec = c
+}
+
+// SetObjectCompression compress object value when encoded size >= threshold,
+// `c` nil for disable compression.
+func SetObjectCompression(c Compressor, threshold int) {
+	if c != nil {
+		RegisterCompressor(c)
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	objectCompressor = c
+	objectCompressAtSize = threshold
+}
+
+// encodeObject JSONCodec without compression write plain json (no header),
+// keep compatible with the values written by old version.
+func encodeObject(v interface{}) ([]byte, error) {
+	codecMu.RLock()
+	codec, compressor, threshold := objectCodec, objectCompressor, objectCompressAtSize
+	codecMu.RUnlock()
+
+	bs, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	header := headerFlag | codec.ID()
+	if compressor != nil && len(bs) >= threshold {
+		if bs, err = compressor.Compress(bs); err != nil {
+			return nil, err
+		}
+		header |= compressor.ID() << 4
+	} else if codec.ID() == JSONCodec.ID() {
+		return bs, nil
+	}
+
+	return append([]byte{header}, bs...), nil
+}
+
+func decodeObject(bs []byte, v interface{}) error {
+	if len(bs) == 0 || bs[0]&headerFlag == 0 {
+		return json.Unmarshal(bs, v)
+	}
+
+	header := bs[0]
+	bs = bs[1:]
+
+	codecMu.RLock()
+	codec, ok := codecs[header&codecMask]
+	compressor, cok := compressors[(header&compressorMask)>>4]
+	codecMu.RUnlock()
+	if !ok {
+		return ErrUnknownCodec
+	}
+
+	if header&compressorMask != 0 {
+		if !cok {
+			return ErrUnknownCompressor
+		}
+		var err error
+		if bs, err = compressor.Decompress(bs); err != nil {
+			return err
+		}
+	}
+
+	return codec.Unmarshal(bs, v)
+}
+
+// ----------------------------------------------------------------------------
+// built-in codecs
+// ----------------------------------------------------------------------------
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte                                   { return 1 }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// gobCodec faster than json for large struct, but only for go, types must be registered
+// by `gob.Register` if encode interface values.
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return 2 }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte                                   { return 3 }
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpackMarshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpackUnmarshal(data, v) }
+
+// ----------------------------------------------------------------------------
+// built-in compressors
+// ----------------------------------------------------------------------------
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) ID() byte { return 1 }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// flateCompressor deflate with best speed, trade ratio for cpu like snappy
+type flateCompressor struct{}
+
+func (flateCompressor) ID() byte { return 2 }
+
+func (flateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
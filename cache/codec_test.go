@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type CodecInner struct {
+	Tags []string `json:"tags"`
+}
+
+type codecValue struct {
+	CodecInner
+	Username string            `json:"username"`
+	Age      int               `json:"age"`
+	Deposit  float64           `json:"deposit"`
+	Negative int64             `json:"negative"`
+	Verified bool              `json:"verified"`
+	Avatar   []byte            `json:"avatar"`
+	Extra    map[string]string `json:"extra"`
+	Birthday time.Time         `json:"birthday"`
+	Parent   *codecValue       `json:"parent,omitempty"`
+	Ignored  string            `json:"-"`
+}
+
+func newCodecValue() codecValue {
+	return codecValue{
+		CodecInner: CodecInner{Tags: []string{"a", "b"}},
+		Username:   "Bob",
+		Age:        45,
+		Deposit:    10000000.89,
+		Negative:   -100000,
+		Verified:   true,
+		Avatar:     []byte{0x00, 0xff},
+		Extra:      map[string]string{"k": strings.Repeat("v", 300)},
+		Birthday:   time.Date(1990, 1, 2, 3, 4, 5, 0, time.UTC),
+		Parent:     &codecValue{Username: "Alice", Birthday: time.Date(1960, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+}
+
+func TestMsgpack(t *testing.T) {
+	value := newCodecValue()
+
+	bs, err := msgpackMarshal(&value)
+	require.Nil(t, err)
+
+	gValue := codecValue{}
+	err = msgpackUnmarshal(bs, &gValue)
+	require.Nil(t, err)
+	assert.EqualValues(t, value, gValue)
+
+	var generic map[string]interface{}
+	err = msgpackUnmarshal(bs, &generic)
+	require.Nil(t, err)
+	assert.EqualValues(t, "Bob", generic["username"])
+	assert.EqualValues(t, -100000, generic["negative"])
+
+	assert.NotNil(t, msgpackUnmarshal(bs[:len(bs)-1], &gValue))
+}
+
+func TestCodecSwitch(t *testing.T) {
+	defer SetObjectCodec(JSONCodec)
+	defer SetObjectCompression(nil, 0)
+
+	value := newCodecValue()
+
+	// default json without header, compatible with old values
+	bs, err := encodeObject(&value)
+	require.Nil(t, err)
+	legacy, _ := json.Marshal(&value)
+	assert.Equal(t, legacy, bs)
+
+	encoded := [][]byte{bs}
+	for _, codec := range []Codec{GobCodec, BinaryCodec} {
+		SetObjectCodec(codec)
+		bs, err := encodeObject(&value)
+		require.Nil(t, err)
+		assert.Equal(t, headerFlag|codec.ID(), bs[0])
+		encoded = append(encoded, bs)
+	}
+
+	SetObjectCodec(JSONCodec)
+	for _, compressor := range []Compressor{GzipCompressor, FlateCompressor} {
+		SetObjectCompression(compressor, 100)
+		bs, err := encodeObject(&value)
+		require.Nil(t, err)
+		assert.Equal(t, headerFlag|compressor.ID()<<4|JSONCodec.ID(), bs[0])
+		assert.True(t, len(bs) < len(legacy))
+		encoded = append(encoded, bs)
+
+		// below threshold not compressed
+		bs, err = encodeObject("short")
+		require.Nil(t, err)
+		assert.Equal(t, `"short"`, string(bs))
+	}
+
+	// all readable whatever codec used now
+	for _, bs := range encoded {
+		gValue := codecValue{}
+		err = decodeObject(bs, &gValue)
+		require.Nil(t, err)
+		assert.EqualValues(t, value, gValue)
+	}
+
+	err = decodeObject([]byte{headerFlag | 0x0f, 0x00}, &codecValue{})
+	assert.Equal(t, ErrUnknownCodec, err)
+}
+
+func TestSetGetObjectCodec(t *testing.T) {
+	defer SetObjectCodec(JSONCodec)
+	defer SetObjectCompression(nil, 0)
+
+	var (
+		key   = "TestSetGetObjectCodec"
+		value = newCodecValue()
+	)
+
+	SetObjectCodec(BinaryCodec)
+	SetObjectCompression(GzipCompressor, 0)
+	err := SetObject(key, &value, time.Second)
+	require.Nil(t, err)
+
+	SetObjectCodec(JSONCodec)
+	SetObjectCompression(nil, 0)
+	gValue := codecValue{}
+	err = GetObject(key, &gValue)
+	require.Nil(t, err)
+	assert.EqualValues(t, value, gValue)
+
+	Del(key)
+}
@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+)
+
+// CatchUp missed runs policy, runs are missed when all replicas down at the tick
+type CatchUp int
+
+const (
+	CatchUpSkip CatchUp = iota // skip missed runs, wait next tick
+	CatchUpOnce                // run once for all missed runs
+	CatchUpAll                 // run every missed run in order, max maxCatchUpRuns
+)
+
+const (
+	maxCatchUpRuns = 100
+	// tick lock min expire, make sure replicas with clock skew not run the same tick again
+	cronLockMinExpire = time.Minute
+)
+
+// CronState job running state, stored in redis shared by all replicas
+type CronState struct {
+	LastRun      time.Time     `json:"last_run"`      // last executed tick
+	LastDuration time.Duration `json:"last_duration"` // last execution duration
+	LastError    string        `json:"last_error"`    // last execution error, "" for success
+	NextRun      time.Time     `json:"next_run"`      // next tick
+	Runner       string        `json:"runner"`        // scheduler id executed last run
+}
+
+type cronJob struct {
+	name     string
+	schedule CronSchedule
+	fn       func() error
+	catchUp  CatchUp
+}
+
+// Cron distributed cron scheduler, every replica run the same jobs on its own timer,
+// each tick lock by distributed lock (never unlocked, expired automatically), so a tick
+// only executed once fleet-wide.
+//
+// Note: a replica never run a job concurrently, but a tick may be executed by other replica
+// when the previous tick still running (job cost time longer than schedule interval).
+type Cron struct {
+	id  string
+	loc *time.Location
+
+	mtx     sync.Mutex
+	jobs    map[string]*cronJob
+	started bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewCron `loc` for cron expression time zone, nil for time.Local
+func NewCron(loc *time.Location) *Cron {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Cron{
+		id:   uuid.New().String(),
+		loc:  loc,
+		jobs: make(map[string]*cronJob),
+		stop: make(chan struct{}),
+	}
+}
+
+// ID scheduler unique id, recorded as CronState.Runner
+func (c *Cron) ID() string {
+	return c.id
+}
+
+// Add register job, `name` must be unique fleet-wide, `spec` see ParseCron.
+// job added after Start will be scheduled immediately.
+func (c *Cron) Add(name string, spec string, fn func() error, catchUp CatchUp) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.jobs[name]; ok {
+		return fmt.Errorf("cron: job %s already exists", name)
+	}
+	j := &cronJob{name: name, schedule: schedule, fn: fn, catchUp: catchUp}
+	c.jobs[name] = j
+	if c.started {
+		c.wg.Add(1)
+		go c.run(j)
+	}
+	return nil
+}
+
+// Start start scheduling in background
+func (c *Cron) Start() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.started {
+		return
+	}
+	c.started = true
+	for _, j := range c.jobs {
+		c.wg.Add(1)
+		go c.run(j)
+	}
+}
+
+// Stop stop scheduling, wait running jobs done
+func (c *Cron) Stop() {
+	c.mtx.Lock()
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	c.mtx.Unlock()
+	c.wg.Wait()
+}
+
+func (c *Cron) run(j *cronJob) {
+	defer c.wg.Done()
+
+	c.catchUp(j)
+
+	now := time.Now().In(c.loc)
+	for {
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-c.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		c.execute(j, next)
+
+		now = time.Now().In(c.loc)
+		if now.Before(next) {
+			now = next
+		}
+	}
+}
+
+// catchUp run missed ticks between stored NextRun and now
+func (c *Cron) catchUp(j *cronJob) {
+	state, err := CronJobState(j.name)
+	if err == NotExist {
+		// first deploy, record next run for missed runs detecting
+		now := time.Now().In(c.loc)
+		saveCronState(j.name, &CronState{NextRun: j.schedule.Next(now)}, true)
+		return
+	}
+	if err != nil || j.catchUp == CatchUpSkip || state.NextRun.IsZero() {
+		return
+	}
+
+	now := time.Now()
+	missed := []time.Time{}
+	for t := state.NextRun.In(c.loc); !t.IsZero() && t.Before(now); t = j.schedule.Next(t) {
+		missed = append(missed, t)
+		if len(missed) >= maxCatchUpRuns {
+			break
+		}
+	}
+	if len(missed) == 0 {
+		return
+	}
+	if j.catchUp == CatchUpOnce {
+		missed = missed[len(missed)-1:]
+	}
+
+	for _, tick := range missed {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		c.execute(j, tick)
+	}
+}
+
+// execute run job if get the tick lock
+func (c *Cron) execute(j *cronJob, tick time.Time) {
+	next := j.schedule.Next(tick)
+	expire := next.Sub(tick)
+	if expire < cronLockMinExpire {
+		expire = cronLockMinExpire
+	}
+	lockName := fmt.Sprintf("%s.%s.%d", cronModule, j.name, tick.Unix())
+	if !Lock(lockName, c.id, expire) {
+		return
+	}
+
+	start := time.Now()
+	err := runCronJob(j.fn)
+	state := &CronState{
+		LastRun:      tick,
+		LastDuration: time.Since(start),
+		NextRun:      next,
+		Runner:       c.id,
+	}
+	if err != nil {
+		state.LastError = err.Error()
+	}
+	saveCronState(j.name, state, false)
+}
+
+func runCronJob(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func saveCronState(name string, state *CronState, nx bool) {
+	var err error
+	defer observe("CronSaveState", time.Now(), &err)
+	cronKey := composeKey2(cronModule, name)
+
+	bs, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if nx {
+		err = redisClient.SetNX(cronKey, bs, 0).Err()
+	} else {
+		err = redisClient.Set(cronKey, bs, 0).Err()
+	}
+}
+
+// CronJobState get job running state, NotExist if job never scheduled
+func CronJobState(name string) (state *CronState, err error) {
+	defer observeGet("CronJobState", time.Now(), &err)
+	cronKey := composeKey2(cronModule, name)
+
+	bs, err := redisClient.Get(cronKey).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, NotExist
+		}
+		return nil, err
+	}
+
+	state = &CronState{}
+	if err := json.Unmarshal(bs, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// CronJobDel delete job running state
+func CronJobDel(name string) {
+	var err error
+	defer observe("CronJobDel", time.Now(), &err)
+	cronKey := composeKey2(cronModule, name)
+	err = redisClient.Del(cronKey).Err()
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCron(t *testing.T) {
+	var (
+		name  = "TestCron"
+		count int64
+	)
+	CronJobDel(name)
+	defer CronJobDel(name)
+
+	job := func() error {
+		atomic.AddInt64(&count, 1)
+		return errors.New("whatever")
+	}
+
+	// replicas run the same job, each tick execute once
+	c1 := NewCron(nil)
+	c2 := NewCron(nil)
+	for _, c := range []*Cron{c1, c2} {
+		err := c.Add(name, "@every 1s", job, CatchUpSkip)
+		require.Nil(t, err)
+		c.Start()
+	}
+	err := c1.Add(name, "@every 1s", job, CatchUpSkip)
+	assert.NotNil(t, err)
+
+	time.Sleep(3500 * time.Millisecond)
+	c1.Stop()
+	c2.Stop()
+
+	n := atomic.LoadInt64(&count)
+	assert.True(t, n >= 3 && n <= 4, n)
+
+	state, err := CronJobState(name)
+	require.Nil(t, err)
+	assert.Equal(t, "whatever", state.LastError)
+	assert.Equal(t, time.Second, state.NextRun.Sub(state.LastRun))
+	assert.Contains(t, []string{c1.ID(), c2.ID()}, state.Runner)
+}
+
+func TestCronCatchUp(t *testing.T) {
+	var (
+		name  = "TestCronCatchUp"
+		count int64
+	)
+	CronJobDel(name)
+	defer CronJobDel(name)
+
+	// all replicas down for a while
+	saveCronState(name, &CronState{NextRun: time.Now().Add(-5 * time.Minute).Truncate(time.Minute)}, false)
+
+	c := NewCron(nil)
+	err := c.Add(name, "@every 1m", func() error {
+		atomic.AddInt64(&count, 1)
+		return nil
+	}, CatchUpAll)
+	require.Nil(t, err)
+	c.Start()
+	time.Sleep(100 * time.Millisecond)
+	c.Stop()
+
+	assert.EqualValues(t, 6, atomic.LoadInt64(&count)) // 5 minutes ago to now
+
+	state, err := CronJobState(name)
+	require.Nil(t, err)
+	assert.True(t, state.NextRun.After(time.Now()))
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule get next activation time after `t`, zero time for never
+type CronSchedule interface {
+	Next(t time.Time) time.Time
+}
+
+// ParseCron parse standard cron expression (5 fields):
+//
+//	┌───────────── minute (0 - 59)
+//	│ ┌───────────── hour (0 - 23)
+//	│ │ ┌───────────── day of the month (1 - 31)
+//	│ │ │ ┌───────────── month (1 - 12 or JAN-DEC)
+//	│ │ │ │ ┌───────────── day of the week (0 - 6 or SUN-SAT, 7 is also Sunday)
+//	│ │ │ │ │
+//	* * * * *
+//
+// field support `*`, `a-b`, `*/n`, `a-b/n`, `a,b,c`. when both day of month and day of week
+// are restricted, match either (same as crontab).
+//
+// descriptors: @yearly(@annually), @monthly, @weekly, @daily(@midnight), @hourly,
+// `@every <duration>` (e.g. "@every 30s", aligned to absolute time so same on all replicas).
+func ParseCron(expr string) (CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		return parseCronDescriptor(expr)
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, found %d: %s", len(fields), expr)
+	}
+
+	var (
+		s   = &cronSpec{}
+		err error
+	)
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, cronDowNames); err != nil {
+		return nil, err
+	}
+	// 7 is Sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+var (
+	cronMonthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronDowNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+func parseCronDescriptor(expr string) (CronSchedule, error) {
+	if spec, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		return ParseCron(spec)
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("cron: %s: %s", expr, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron: %s: duration less than 1s", expr)
+		}
+		return cronEvery(d), nil
+	}
+	return nil, fmt.Errorf("cron: unrecognized descriptor: %s", expr)
+}
+
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// parseCronField parse field to bitset
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		var (
+			rng  = part
+			step = 1
+			err  error
+		)
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("cron: invalid step: %s", part)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			if lo, err = parseCronValue(rng[:i], names); err != nil {
+				return 0, fmt.Errorf("cron: invalid range: %s", part)
+			}
+			if hi, err = parseCronValue(rng[i+1:], names); err != nil {
+				return 0, fmt.Errorf("cron: invalid range: %s", part)
+			}
+		default:
+			if lo, err = parseCronValue(rng, names); err != nil {
+				return 0, fmt.Errorf("cron: invalid value: %s", part)
+			}
+			hi = lo
+			// `a/n` means `a-max/n`
+			if strings.Contains(part, "/") {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("cron: %s out of range [%d, %d]", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+func (s *cronSpec) dayMatch(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next search matched time field by field, give up after 5 years (e.g. "0 0 30 2 *")
+func (s *cronSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatch(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// cronEvery fixed interval aligned to absolute time (see time.Truncate)
+type cronEvery time.Duration
+
+func (e cronEvery) Next(t time.Time) time.Time {
+	d := time.Duration(e)
+	return t.Truncate(d).Add(d)
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2021, 3, 15, 10, 30, 45, 0, time.UTC) // Monday
+
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 3, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/2 * * *", time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2021, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * SUN", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"30 8 1 jan-mar *", time.Date(2022, 1, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 0 20 * 3", time.Date(2021, 3, 17, 0, 0, 0, 0, time.UTC)}, // day of month or day of week
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5,10 10 * * *", time.Date(2021, 3, 16, 10, 5, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 1m", time.Date(2021, 3, 15, 10, 31, 0, 0, time.UTC)},
+		{"@every 10s", time.Date(2021, 3, 15, 10, 30, 50, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.spec)
+		require.Nil(t, err, c.spec)
+		assert.Equal(t, c.next, s.Next(base), c.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@never"} {
+		_, err := ParseCron(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
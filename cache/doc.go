@@ -5,7 +5,9 @@ Basic
 
   - Init/Close
   - string/int/int64/float64/object Getter/Setter Delete
+  - object codec (json/gob/msgpack binary) and compression (gzip/flate), switch codec safely
   - TTL/PTTL
+  - optimistic read-modify-write object `Update`, retry on conflict (WATCH/MULTI)
   - compose redis key used appname/module prevent key repeat
 
 Distribute Lock: support lock/unlock on distributed environment.
@@ -15,11 +17,42 @@ Distribute Lock: support lock/unlock on distributed environment.
 
 Note: not consider redis server down caused deadlock.
 
+Leader: leader election based on distributed lock, leader keep renewing lock, notify leadership changes.
+
+Cron: distributed cron scheduler, every tick only executed once fleet-wide, support missed runs catch-up.
+
+Semaphore: distributed counting semaphore, allow max N holders at the same time,
+holder lease expired (not renewed) will be reclaimed automatically.
+
+Idempotency: reserve idempotency key atomically, store and replay the first response.
+
+Session: server-side session with sliding expiration, list/revoke sessions of a user.
+
+VCode: verify code storage, resend cooldown, send quota, limited verify attempts.
+
+Revoke: token revocation list, revoke a token (atomically, once) or all tokens of a subject issued before a time.
+
+RBAC: cached roles of users for role based access control.
+
 Message Queue: based on redis data structure `list` map to a message queue. and `right push`, `left pop`.
 
 Counter: a global counter.
 
-SS: string set.
+Window Counter: sliding time window counter, sum over trailing window and per bucket series.
+
+Geo: geo location, nearest members within radius with distance and coordinates, paging.
+
+SS: string set, server-side union/intersection/difference (and store), pop/move, cursor scan.
+
+Breaker: optional circuit breaker, fail fast with ErrCircuitOpen while redis unavailable, serve object/string
+getters from local near-cache, half-open to probe recovery, `Health()` for health check.
+
+Scan: iterate/purge/inspect keys under app namespace by module, see also command `cmd/cblcache`.
+
+Hook: instrumentation for operation latency/errors, getter hit/miss, lock events and mq depth,
+`cbl.PromCacheHook()` collect them to prometheus (`cbl.PromCacheHookWithKeys` to label lock/mq keys, required for mq depth).
+
+Testing: package `cache/cachetest` in-process fake redis server, covered commands used by this package.
 
 if all method can't meet your needs, welcome PR or `C()` expose redis client, you can use native redis library.
 */
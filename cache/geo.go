@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// -----------------------------------------------------------------------------
+// geo location
+// locations stored in sorted set by GEOADD, searched by GEORADIUS (read only variant,
+// available since redis 3.2, GEOSEARCH needs redis 6.2). distance unit is meter.
+// -----------------------------------------------------------------------------
+
+var (
+	ErrGeoInvalidRadius = fmt.Errorf("geo search radius must be positive")
+)
+
+// GeoLocation member location
+type GeoLocation struct {
+	Member    string  `json:"member"`
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+}
+
+// GeoResult search result, ordered by distance
+type GeoResult struct {
+	GeoLocation
+	Distance float64 `json:"distance"` // meters from search center
+}
+
+// GeoQuery search options
+type GeoQuery struct {
+	Radius float64 // meters
+	Offset int     // paging, skip nearest `Offset` members
+	Limit  int     // paging, max results returned, 0 for all
+	Desc   bool    // farthest first
+}
+
+func geoKey(key string) string {
+	return composeKey2(geoModule, key)
+}
+
+// GeoAdd add or update members location, return count of new added members
+func GeoAdd(key string, locations ...GeoLocation) (n int64, err error) {
+	defer observe("GeoAdd", time.Now(), &err)
+	if len(locations) == 0 {
+		return 0, nil
+	}
+	ls := make([]*redis.GeoLocation, 0, len(locations))
+	for _, l := range locations {
+		ls = append(ls, &redis.GeoLocation{Name: l.Member, Longitude: l.Longitude, Latitude: l.Latitude})
+	}
+	return redisClient.GeoAdd(geoKey(key), ls...).Result()
+}
+
+// GeoRem remove members, return count of removed members
+func GeoRem(key string, members ...string) (n int64, err error) {
+	defer observe("GeoRem", time.Now(), &err)
+	t := make([]interface{}, 0, len(members))
+	for _, m := range members {
+		t = append(t, m)
+	}
+	return redisClient.ZRem(geoKey(key), t...).Result()
+}
+
+// GeoCount member count
+func GeoCount(key string) int64 {
+	var err error
+	defer observe("GeoCount", time.Now(), &err)
+	n, err := redisClient.ZCard(geoKey(key)).Result()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// GeoDel delete all members
+func GeoDel(key string) (err error) {
+	defer observe("GeoDel", time.Now(), &err)
+	return redisClient.Del(geoKey(key)).Err()
+}
+
+// GeoPos members location, nil for member not exist
+func GeoPos(key string, members ...string) (locations []*GeoLocation, err error) {
+	defer observe("GeoPos", time.Now(), &err)
+	positions, err := redisClient.GeoPos(geoKey(key), members...).Result()
+	if err != nil {
+		return nil, err
+	}
+	locations = make([]*GeoLocation, 0, len(positions))
+	for i, pos := range positions {
+		if pos == nil {
+			locations = append(locations, nil)
+			continue
+		}
+		locations = append(locations, &GeoLocation{Member: members[i], Longitude: pos.Longitude, Latitude: pos.Latitude})
+	}
+	return locations, nil
+}
+
+// GeoDist distance between two members in meters, NotExist if any member not exist
+func GeoDist(key string, member1 string, member2 string) (d float64, err error) {
+	defer observeGet("GeoDist", time.Now(), &err)
+	d, err = redisClient.GeoDist(geoKey(key), member1, member2, "m").Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, NotExist
+		}
+		return 0, err
+	}
+	return d, nil
+}
+
+// GeoSearch members within radius of the point (longitude, latitude), nearest first,
+// `more` true if there are more results after this page.
+//
+//	// nearest 10 shops within 5km
+//	shops, _, err := cache.GeoSearch("shops", 116.39, 39.91, &cache.GeoQuery{Radius: 5000, Limit: 10})
+func GeoSearch(key string, longitude float64, latitude float64, q *GeoQuery) (results []GeoResult, more bool, err error) {
+	defer observe("GeoSearch", time.Now(), &err)
+	rq, err := q.radiusQuery()
+	if err != nil {
+		return nil, false, err
+	}
+	locations, err := redisClient.GeoRadius(geoKey(key), longitude, latitude, rq).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	results, more = q.page(locations)
+	return results, more, nil
+}
+
+// GeoSearchByMember members within radius of the member (include itself), NotExist if member not exist
+func GeoSearchByMember(key string, member string, q *GeoQuery) (results []GeoResult, more bool, err error) {
+	defer observe("GeoSearchByMember", time.Now(), &err)
+	rq, err := q.radiusQuery()
+	if err != nil {
+		return nil, false, err
+	}
+	locations, err := redisClient.GeoRadiusByMember(geoKey(key), member, rq).Result()
+	if err != nil {
+		if isGeoMemberNotExist(err) {
+			return nil, false, NotExist
+		}
+		return nil, false, err
+	}
+	results, more = q.page(locations)
+	return results, more, nil
+}
+
+// isGeoMemberNotExist redis reply "ERR could not decode requested zset member"
+func isGeoMemberNotExist(err error) bool {
+	_, ok := err.(redis.Error)
+	return ok && err.Error() == "ERR could not decode requested zset member"
+}
+
+// radiusQuery fetch one more than the page to know if more results
+func (q *GeoQuery) radiusQuery() (*redis.GeoRadiusQuery, error) {
+	if q.Radius <= 0 {
+		return nil, ErrGeoInvalidRadius
+	}
+	rq := &redis.GeoRadiusQuery{
+		Radius:    q.Radius,
+		Unit:      "m",
+		WithCoord: true,
+		WithDist:  true,
+		Sort:      "ASC",
+	}
+	if q.Desc {
+		rq.Sort = "DESC"
+	}
+	if q.Limit > 0 {
+		rq.Count = q.offset() + q.Limit + 1
+	}
+	return rq, nil
+}
+
+func (q *GeoQuery) offset() int {
+	if q.Offset < 0 {
+		return 0
+	}
+	return q.Offset
+}
+
+func (q *GeoQuery) page(locations []redis.GeoLocation) ([]GeoResult, bool) {
+	results := []GeoResult{}
+	if q.offset() >= len(locations) {
+		return results, false
+	}
+	locations = locations[q.offset():]
+	more := false
+	if q.Limit > 0 && len(locations) > q.Limit {
+		locations, more = locations[:q.Limit], true
+	}
+	for _, l := range locations {
+		results = append(results, GeoResult{
+			GeoLocation: GeoLocation{Member: l.Name, Longitude: l.Longitude, Latitude: l.Latitude},
+			Distance:    l.Dist,
+		})
+	}
+	return results, more
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeo(t *testing.T) {
+	key := "TestGeo"
+	GeoDel(key)
+	defer GeoDel(key)
+
+	// around Tiananmen
+	n, err := GeoAdd(key,
+		GeoLocation{Member: "a", Longitude: 116.3975, Latitude: 39.9087},
+		GeoLocation{Member: "b", Longitude: 116.4075, Latitude: 39.9087}, // ~850m east
+		GeoLocation{Member: "c", Longitude: 116.3975, Latitude: 39.9387}, // ~3.3km north
+		GeoLocation{Member: "d", Longitude: 121.4737, Latitude: 31.2304}, // shanghai
+	)
+	require.Nil(t, err)
+	assert.EqualValues(t, 4, n)
+	assert.EqualValues(t, 4, GeoCount(key))
+
+	locations, err := GeoPos(key, "a", "none")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(locations))
+	assert.InDelta(t, 116.3975, locations[0].Longitude, 0.0001)
+	assert.InDelta(t, 39.9087, locations[0].Latitude, 0.0001)
+	assert.Nil(t, locations[1])
+
+	d, err := GeoDist(key, "a", "b")
+	require.Nil(t, err)
+	assert.InDelta(t, 853, d, 5)
+	_, err = GeoDist(key, "a", "none")
+	assert.Equal(t, NotExist, err)
+
+	// within 5km, nearest first
+	results, more, err := GeoSearch(key, 116.3975, 39.9087, &GeoQuery{Radius: 5000})
+	require.Nil(t, err)
+	assert.False(t, more)
+	require.Equal(t, 3, len(results))
+	assert.Equal(t, "a", results[0].Member)
+	assert.Equal(t, "b", results[1].Member)
+	assert.Equal(t, "c", results[2].Member)
+	assert.InDelta(t, 853, results[1].Distance, 5)
+	assert.InDelta(t, 116.4075, results[1].Longitude, 0.0001)
+
+	// paging
+	results, more, err = GeoSearch(key, 116.3975, 39.9087, &GeoQuery{Radius: 5000, Offset: 1, Limit: 1})
+	require.Nil(t, err)
+	assert.True(t, more)
+	require.Equal(t, 1, len(results))
+	assert.Equal(t, "b", results[0].Member)
+	results, more, err = GeoSearch(key, 116.3975, 39.9087, &GeoQuery{Radius: 5000, Offset: 2, Limit: 1})
+	require.Nil(t, err)
+	assert.False(t, more)
+	assert.Equal(t, "c", results[0].Member)
+
+	results, _, err = GeoSearchByMember(key, "c", &GeoQuery{Radius: 1000})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(results))
+	assert.Equal(t, "c", results[0].Member)
+	_, _, err = GeoSearchByMember(key, "none", &GeoQuery{Radius: 1000})
+	assert.Equal(t, NotExist, err)
+
+	_, _, err = GeoSearch(key, 0, 0, &GeoQuery{})
+	assert.Equal(t, ErrGeoInvalidRadius, err)
+
+	n, err = GeoRem(key, "a", "none")
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, n)
+	assert.EqualValues(t, 3, GeoCount(key))
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockEvent distributed lock event
+type LockEvent int
+
+const (
+	LockAcquired  LockEvent = iota // lock acquired
+	LockContended                  // lock held by others, acquire failure
+	LockTimeout                    // TryLock wait timeout
+	LockReleased                   // lock released by UnLock
+)
+
+func (e LockEvent) String() string {
+	switch e {
+	case LockAcquired:
+		return "acquired"
+	case LockContended:
+		return "contended"
+	case LockTimeout:
+		return "timeout"
+	case LockReleased:
+		return "released"
+	}
+	return "unknown"
+}
+
+// Hook cache instrumentation, methods are called synchronously in the operation
+// goroutine, must be fast and goroutine safe.
+// embed NopHook if only care part of events.
+type Hook interface {
+	// Operation every operation done, `op` is method name e.g. "GetObject",
+	// `err` nil for success (NotExist/CounterZero are not errors)
+	Operation(op string, latency time.Duration, err error)
+	// Hit getter result, miss when key not exist (getter return NotExist)
+	Hit(op string, hit bool)
+	// Lock distributed lock event, `wait` is acquire wait duration
+	Lock(name string, event LockEvent, wait time.Duration)
+	// MQDepth message queue depth, reported by MQPush/MQLen
+	MQDepth(key string, depth int64)
+}
+
+// NopHook hook do nothing
+type NopHook struct{}
+
+func (NopHook) Operation(op string, latency time.Duration, err error) {}
+func (NopHook) Hit(op string, hit bool)                               {}
+func (NopHook) Lock(name string, event LockEvent, wait time.Duration) {}
+func (NopHook) MQDepth(key string, depth int64)                       {}
+
+var (
+	hooksMu sync.Mutex   // for writers, readers lock free
+	hooks   atomic.Value // []Hook
+)
+
+// AddHook add instrumentation hook, recommend add before InitCache
+func AddHook(h Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	old, _ := hooks.Load().([]Hook)
+	hs := make([]Hook, 0, len(old)+1)
+	hs = append(hs, old...)
+	hooks.Store(append(hs, h))
+}
+
+// ResetHooks remove all hooks
+func ResetHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks.Store([]Hook{})
+}
+
+func loadHooks() []Hook {
+	hs, _ := hooks.Load().([]Hook)
+	return hs
+}
+
+// opError NotExist/CounterZero are expected results, not operation failure
+func opError(err error) error {
+	if err == NotExist || err == CounterZero {
+		return nil
+	}
+	return err
+}
+
+// observe report operation, used as `defer observe("op", time.Now(), &err)`
+func observe(op string, start time.Time, err *error) {
+	hs := loadHooks()
+	if len(hs) == 0 {
+		return
+	}
+	latency := time.Since(start)
+	e := opError(*err)
+	for _, h := range hs {
+		h.Operation(op, latency, e)
+	}
+}
+
+// observeGet report getter operation and hit/miss, NotExist is a miss not an error
+func observeGet(op string, start time.Time, err *error) {
+	hs := loadHooks()
+	if len(hs) == 0 {
+		return
+	}
+	latency := time.Since(start)
+	e := opError(*err)
+	for _, h := range hs {
+		h.Operation(op, latency, e)
+		if e == nil {
+			h.Hit(op, *err == nil)
+		}
+	}
+}
+
+func observeLock(name string, event LockEvent, wait time.Duration) {
+	for _, h := range loadHooks() {
+		h.Lock(name, event, wait)
+	}
+}
+
+func observeMQDepth(key string, depth int64) {
+	for _, h := range loadHooks() {
+		h.MQDepth(key, depth)
+	}
+}
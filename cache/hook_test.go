@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordHook struct {
+	NopHook
+	mtx    sync.Mutex
+	ops    []string
+	hits   []bool
+	events []LockEvent
+	depth  int64
+}
+
+func (h *recordHook) Operation(op string, latency time.Duration, err error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.ops = append(h.ops, op)
+}
+
+func (h *recordHook) Hit(op string, hit bool) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.hits = append(h.hits, hit)
+}
+
+func (h *recordHook) Lock(name string, event LockEvent, wait time.Duration) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *recordHook) MQDepth(key string, depth int64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.depth = depth
+}
+
+func TestHook(t *testing.T) {
+	var (
+		key = "TestHook"
+		h   = &recordHook{}
+	)
+	AddHook(h)
+	defer ResetHooks()
+
+	err := SetString(key, "whatever", time.Second)
+	require.Nil(t, err)
+	_, err = GetString(key)
+	require.Nil(t, err)
+	Del(key)
+	_, err = GetString(key)
+	require.Equal(t, NotExist, err)
+
+	assert.EqualValues(t, []string{"SetString", "GetString", "Del", "GetString"}, h.ops)
+	assert.EqualValues(t, []bool{true, false}, h.hits)
+
+	require.True(t, Lock(key, "ticket_1", time.Second))
+	require.False(t, TryLock(key, "ticket_2", time.Second, 20*time.Millisecond))
+	require.Nil(t, UnLock(key, "ticket_1"))
+	assert.EqualValues(t, []LockEvent{LockAcquired, LockContended, LockTimeout, LockReleased}, h.events)
+
+	MQDel(key)
+	MQPush(key, []byte("1"))
+	MQPush(key, []byte("2"))
+	assert.EqualValues(t, 2, h.depth)
+	MQDel(key)
+}
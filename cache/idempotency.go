@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
+)
+
+// -----------------------------------------------------------------------------
+// idempotency key store
+// request reserve the key before processing, store the response after processed,
+// duplicate requests replay the stored response.
+// -----------------------------------------------------------------------------
+
+var ErrIdemInProgress = fmt.Errorf("idempotency key in progress")
+
+// IdemResponse stored response of the first request
+type IdemResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+const idemPending = "pending"
+
+// IdemReserve atomically reserve the key, `timeout` is max processing duration,
+// reservation expired after timeout (e.g. process crashed) and the key can be reserved again.
+//   - (nil, nil) reserved, process the request then IdemComplete (or IdemRelease for retry)
+//   - (resp, nil) completed before, replay the response
+//   - (nil, ErrIdemInProgress) reserved by a concurrent duplicate request
+func IdemReserve(key string, timeout time.Duration) (resp *IdemResponse, err error) {
+	defer observe("IdemReserve", time.Now(), &err)
+	idemKey := composeKey2(idemModule, key)
+
+	v, err := redisClient.Eval(scripts.IdemReserve, []string{idemKey}, idemPending, timeout.Milliseconds()).Text()
+	if err != nil {
+		return nil, err
+	}
+	switch v {
+	case "":
+		return nil, nil
+	case idemPending:
+		return nil, ErrIdemInProgress
+	}
+
+	resp = &IdemResponse{}
+	if err := json.Unmarshal([]byte(v), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// IdemComplete store the response, duplicate requests in `expire` replay it
+func IdemComplete(key string, resp *IdemResponse, expire time.Duration) (err error) {
+	defer observe("IdemComplete", time.Now(), &err)
+	idemKey := composeKey2(idemModule, key)
+
+	bs, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return redisClient.Set(idemKey, bs, expire).Err()
+}
+
+// IdemRelease remove the reservation (or stored response), allow request retry
+func IdemRelease(key string) (err error) {
+	defer observe("IdemRelease", time.Now(), &err)
+	idemKey := composeKey2(idemModule, key)
+	return redisClient.Del(idemKey).Err()
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	key := "TestIdempotency"
+	IdemRelease(key)
+	defer IdemRelease(key)
+
+	resp, err := IdemReserve(key, time.Second)
+	require.Nil(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = IdemReserve(key, time.Second)
+	assert.Equal(t, ErrIdemInProgress, err)
+	assert.Nil(t, resp)
+
+	stored := &IdemResponse{Status: 200, ContentType: "application/json", Body: []byte(`{"code":0}`)}
+	err = IdemComplete(key, stored, time.Second)
+	require.Nil(t, err)
+
+	resp, err = IdemReserve(key, time.Second)
+	require.Nil(t, err)
+	assert.EqualValues(t, stored, resp)
+
+	// released, reserve again
+	IdemRelease(key)
+	resp, err = IdemReserve(key, time.Second)
+	require.Nil(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,151 @@
+// Package scripts lua scripts used by package cache, shared with package cachetest
+// which emulates them by script text. a script added or changed here must be emulated
+// (or updated) in cachetest/scripts.go as well, tests run against the emulation.
+package scripts
+
+// KEYS[1] idempotency key
+// ARGV[1] pending flag, ARGV[2] pending expire
+// return "" for reserved, else the existing value
+const IdemReserve = `
+local v = redis.call("GET", KEYS[1])
+if v == false then
+   redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+   return ""
+end
+return v
+`
+
+// KEYS[1] lock key
+// ARGV[1] ticket, ARGV[2] expire
+const RenewLock = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+   return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`
+
+// KEYS[1] counter key
+// return -2 for not exist, -1 for zero, else decremented value
+const CounterDecrMinZero = `
+local v = redis.call("GET", KEYS[1])
+if v == false then
+   return -2
+end
+
+if tonumber(v) > 0 then
+   return redis.call("DECR", KEYS[1])
+else
+   return -1
+end
+`
+
+// KEYS[1] semaphore key
+// ARGV[1] now, ARGV[2] limit, ARGV[3] holder, ARGV[4] lease expire at, ARGV[5] lease
+const SemAcquire = `
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZSCORE", KEYS[1], ARGV[3]) == false then
+   if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+      return 0
+   end
+end
+redis.call("ZADD", KEYS[1], ARGV[4], ARGV[3])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[5]) then
+   redis.call("PEXPIRE", KEYS[1], ARGV[5])
+end
+return 1
+`
+
+// KEYS[1] semaphore key
+// ARGV[1] now, ARGV[2] holder, ARGV[3] lease expire at, ARGV[4] lease
+const SemRenew = `
+local score = redis.call("ZSCORE", KEYS[1], ARGV[2])
+if score == false or tonumber(score) <= tonumber(ARGV[1]) then
+   return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[2])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[4]) then
+   redis.call("PEXPIRE", KEYS[1], ARGV[4])
+end
+return 1
+`
+
+// KEYS[1] code, KEYS[2] cooldown, KEYS[3] quota
+// ARGV[1] code, ARGV[2] ttl, ARGV[3] cooldown, ARGV[4] attempts, ARGV[5] quota, ARGV[6] quota expire at
+const VCodeIssue = `
+if redis.call("EXISTS", KEYS[2]) == 1 then
+   return -1
+end
+local quota = tonumber(ARGV[5])
+if quota > 0 and tonumber(redis.call("GET", KEYS[3]) or "0") >= quota then
+   return -2
+end
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[1], "code", ARGV[1], "attempts", ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+   redis.call("SET", KEYS[2], "1", "PX", ARGV[3])
+end
+if quota > 0 then
+   redis.call("INCR", KEYS[3])
+   redis.call("PEXPIREAT", KEYS[3], ARGV[6])
+end
+return 0
+`
+
+// KEYS[1] code
+// ARGV[1] code
+const VCodeVerify = `
+local code = redis.call("HGET", KEYS[1], "code")
+if code == false then
+   return -1
+end
+if code == ARGV[1] then
+   redis.call("DEL", KEYS[1])
+   return 1
+end
+if redis.call("HINCRBY", KEYS[1], "attempts", -1) <= 0 then
+   redis.call("DEL", KEYS[1])
+end
+return 0
+`
+
+// KEYS[1] code, KEYS[2] cooldown, KEYS[3] quota
+const VCodeRevoke = `
+redis.call("DEL", KEYS[1], KEYS[2])
+if tonumber(redis.call("GET", KEYS[3]) or "0") > 0 then
+   redis.call("DECR", KEYS[3])
+end
+return 1
+`
+
+// KEYS[1] window key
+// ARGV[1] bucket, ARGV[2] n, ARGV[3] oldest alive bucket, ARGV[4] retention
+// bucket older than oldest ignored (would never be collected), return 0
+const WindowIncr = `
+if tonumber(ARGV[1]) < tonumber(ARGV[3]) then
+   return 0
+end
+local v = redis.call("HINCRBY", KEYS[1], ARGV[1], ARGV[2])
+local gc = tonumber(redis.call("HGET", KEYS[1], "_gc") or "0")
+if gc < tonumber(ARGV[1]) then
+   local oldest = tonumber(ARGV[3])
+   for _, f in ipairs(redis.call("HKEYS", KEYS[1])) do
+      if f ~= "_gc" and tonumber(f) < oldest then
+         redis.call("HDEL", KEYS[1], f)
+      end
+   end
+   redis.call("HSET", KEYS[1], "_gc", ARGV[1])
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return v
+`
+
+// KEYS[1] session key
+// ARGV[1] field, ARGV[2] value
+// HSET on a expired session create a key without expiration, only set when exists
+const SessionSet = `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+   return redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+end
+return -1
+`
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Leader leader election based on distributed lock, replicas campaign for the same
+// lock `name`, the holder is leader and keep renewing the lock. leader step down when
+// renew failure (lock expired or redis failure), other replicas take over after lock expired.
+//
+// election lock shares keys with Lock/TryLock, so it can be migrated from hand-written TryLock.
+//
+//	l := cache.NewLeader("cron", cbl.GenUUIDV4(), 10*time.Second)
+//	l.Start()
+//	defer l.Stop()
+//	...
+//	if l.IsLeader() {
+//		// do job
+//	}
+type Leader struct {
+	name     string
+	id       string
+	ttl      time.Duration
+	interval time.Duration
+
+	mtx      sync.Mutex
+	leader   bool
+	changes  chan bool
+	stop     chan struct{}
+	done     chan struct{}
+	started  bool
+	stopOnce sync.Once
+}
+
+// NewLeader `id` unique id of the candidate (lock ticket), `ttl` lock expire duration,
+// leader renew the lock every ttl/3, so the max leaderless time is about ttl after leader dead.
+func NewLeader(name string, id string, ttl time.Duration) *Leader {
+	return &Leader{
+		name:     name,
+		id:       id,
+		ttl:      ttl,
+		interval: ttl / 3,
+		changes:  make(chan bool, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// ID candidate id
+func (l *Leader) ID() string {
+	return l.id
+}
+
+// IsLeader current is leader or not
+func (l *Leader) IsLeader() bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.leader
+}
+
+// Changes leadership change notification, true for become leader, false for step down.
+// only keep the latest change if not received in time, closed after Stop.
+func (l *Leader) Changes() <-chan bool {
+	return l.changes
+}
+
+// Start campaign in background, only start once, can't restart after Stop
+func (l *Leader) Start() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.started {
+		return
+	}
+	select {
+	case <-l.stop:
+		return
+	default:
+	}
+	l.started = true
+	go l.run()
+}
+
+// Stop stop campaign, step down if it is leader (release lock, let others take over immediately)
+func (l *Leader) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+
+		l.mtx.Lock()
+		started := l.started
+		l.mtx.Unlock()
+		if started {
+			<-l.done
+		}
+
+		if l.IsLeader() {
+			UnLock(l.name, l.id)
+			l.setLeader(false)
+		}
+		close(l.changes)
+	})
+}
+
+func (l *Leader) setLeader(leader bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.leader == leader {
+		return
+	}
+	l.leader = leader
+
+	// drop the stale change not received
+	select {
+	case <-l.changes:
+	default:
+	}
+	l.changes <- leader
+}
+
+func (l *Leader) campaign() {
+	if l.IsLeader() {
+		if err := RenewLock(l.name, l.id, l.ttl); err != nil {
+			// redis failure can't make sure still hold lock, step down
+			l.setLeader(false)
+		}
+		return
+	}
+	// lock may still held by self after a transient redis failure
+	if Lock(l.name, l.id, l.ttl) || RenewLock(l.name, l.id, l.ttl) == nil {
+		l.setLeader(true)
+	}
+}
+
+func (l *Leader) run() {
+	defer close(l.done)
+
+	l.campaign()
+
+	t := time.NewTicker(l.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			l.campaign()
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeader(t *testing.T) {
+	var (
+		name = "TestLeader"
+		ttl  = 300 * time.Millisecond
+	)
+
+	l1 := NewLeader(name, "candidate_1", ttl)
+	l1.Start()
+	require.True(t, <-l1.Changes())
+	assert.True(t, l1.IsLeader())
+
+	l2 := NewLeader(name, "candidate_2", ttl)
+	l2.Start()
+	defer l2.Stop()
+
+	// leader keep renewing, l2 can't take over
+	time.Sleep(2 * ttl)
+	assert.True(t, l1.IsLeader())
+	assert.False(t, l2.IsLeader())
+
+	// l1 step down, l2 take over
+	l1.Stop()
+	assert.False(t, l1.IsLeader())
+	leader, ok := <-l1.Changes()
+	assert.True(t, ok)
+	assert.False(t, leader)
+	_, ok = <-l1.Changes()
+	assert.False(t, ok)
+
+	select {
+	case leader := <-l2.Changes():
+		assert.True(t, leader)
+	case <-time.After(2 * ttl):
+		t.Fatal("l2 not take over")
+	}
+	assert.True(t, l2.IsLeader())
+}
@@ -0,0 +1,676 @@
+package cache
+
+// msgpack binary format (https://github.com/msgpack/msgpack/blob/master/spec.md),
+// only a subset without extension types, enough for cache objects.
+//
+// struct encoded as map, field name via tag `msgpack`, fallback `json`, support
+// "-" and "omitempty". types implement encoding.BinaryMarshaler (e.g. time.Time)
+// encoded as bin.
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+func msgpackMarshal(v interface{}) ([]byte, error) {
+	e := &mpEncoder{buf: make([]byte, 0, 64)}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func msgpackUnmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: unmarshal need non-nil pointer, got %T", v)
+	}
+
+	d := &mpDecoder{buf: data}
+	value, err := d.decode()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return fmt.Errorf("msgpack: %d trailing bytes", len(d.buf)-d.pos)
+	}
+	return mpAssign(rv.Elem(), value)
+}
+
+// ----------------------------------------------------------------------------
+// struct fields
+// ----------------------------------------------------------------------------
+
+type mpField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+func mpStructFields(t reflect.Type) []mpField {
+	fields := []mpField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("msgpack")
+		if tag == "" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+
+		// flatten embedded struct like encoding/json
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, sub := range mpStructFields(f.Type) {
+				sub.index = append([]int{i}, sub.index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := mpField{name: name, index: []int{i}}
+		for _, opt := range opts[1:] {
+			if opt == "omitempty" {
+				field.omitEmpty = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func mpIsEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// encoder
+// ----------------------------------------------------------------------------
+
+var binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+
+type mpEncoder struct {
+	buf []byte
+}
+
+func (e *mpEncoder) writeUint(prefix byte, n uint64, size int) {
+	e.buf = append(e.buf, prefix)
+	switch size {
+	case 1:
+		e.buf = append(e.buf, byte(n))
+	case 2:
+		e.buf = append(e.buf, 0, 0)
+		binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(n))
+	case 4:
+		e.buf = append(e.buf, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	case 8:
+		e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], n)
+	}
+}
+
+func (e *mpEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.writeUint(0xd0, uint64(n), 1)
+	case n >= math.MinInt16:
+		e.writeUint(0xd1, uint64(n), 2)
+	case n >= math.MinInt32:
+		e.writeUint(0xd2, uint64(n), 4)
+	default:
+		e.writeUint(0xd3, uint64(n), 8)
+	}
+}
+
+func (e *mpEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xcc, n, 1)
+	case n <= math.MaxUint16:
+		e.writeUint(0xcd, n, 2)
+	case n <= math.MaxUint32:
+		e.writeUint(0xce, n, 4)
+	default:
+		e.writeUint(0xcf, n, 8)
+	}
+}
+
+func (e *mpEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xd9, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.writeUint(0xda, uint64(n), 2)
+	default:
+		e.writeUint(0xdb, uint64(n), 4)
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *mpEncoder) encodeBytes(bs []byte) {
+	n := len(bs)
+	switch {
+	case n <= math.MaxUint8:
+		e.writeUint(0xc4, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.writeUint(0xc5, uint64(n), 2)
+	default:
+		e.writeUint(0xc6, uint64(n), 4)
+	}
+	e.buf = append(e.buf, bs...)
+}
+
+func (e *mpEncoder) encodeArrayLen(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xdc, uint64(n), 2)
+	default:
+		e.writeUint(0xdd, uint64(n), 4)
+	}
+}
+
+func (e *mpEncoder) encodeMapLen(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xde, uint64(n), 2)
+	default:
+		e.writeUint(0xdf, uint64(n), 4)
+	}
+}
+
+func (e *mpEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+
+	if v.Type().Implements(binaryMarshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		bs, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		e.encodeBytes(bs)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.writeUint(0xca, uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Float64:
+		e.writeUint(0xcb, math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		e.encodeArrayLen(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		e.encodeMapLen(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := mpStructFields(v.Type())
+		values := make([]reflect.Value, 0, len(fields))
+		names := make([]string, 0, len(fields))
+		for _, f := range fields {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && mpIsEmpty(fv) {
+				continue
+			}
+			values = append(values, fv)
+			names = append(names, f.name)
+		}
+		e.encodeMapLen(len(values))
+		for i := range values {
+			e.encodeString(names[i])
+			if err := e.encode(values[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// decoder, decode to generic value first, then assign to target by reflect
+// ----------------------------------------------------------------------------
+
+type mpDecoder struct {
+	buf []byte
+	pos int
+}
+
+var errMsgpackShort = fmt.Errorf("msgpack: unexpected end of data")
+
+func (d *mpDecoder) read(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, errMsgpackShort
+	}
+	bs := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return bs, nil
+}
+
+func (d *mpDecoder) readUint(size int) (uint64, error) {
+	bs, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(bs[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(bs)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(bs)), nil
+	default:
+		return binary.BigEndian.Uint64(bs), nil
+	}
+}
+
+func (d *mpDecoder) readLen(size int) (int, error) {
+	n, err := d.readUint(size)
+	return int(n), err
+}
+
+func (d *mpDecoder) decode() (interface{}, error) {
+	bs, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	c := bs[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.decodeString(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.decodeArray(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.decodeMap(int(c & 0x0f))
+	}
+
+	var n int
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.readUint(1 << (c - 0xcc))
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		if n, err = d.readLen(1 << (c - 0xd9)); err != nil {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = d.readLen(1 << (c - 0xc4)); err != nil {
+			return nil, err
+		}
+		bs, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, bs...), nil
+	case 0xdc, 0xdd:
+		if n, err = d.readLen(2 << (c - 0xdc)); err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		if n, err = d.readLen(2 << (c - 0xde)); err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%x", c)
+}
+
+func (d *mpDecoder) decodeString(n int) (interface{}, error) {
+	bs, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(bs), nil
+}
+
+func (d *mpDecoder) decodeArray(n int) (interface{}, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, errMsgpackShort
+	}
+	a := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+// decodeMap all keys string return map[string]interface{}, else map[interface{}]interface{}
+func (d *mpDecoder) decodeMap(n int) (interface{}, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, errMsgpackShort
+	}
+	keys := make([]interface{}, 0, n)
+	values := make([]interface{}, 0, n)
+	allString := true
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			allString = false
+		}
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	if allString {
+		m := make(map[string]interface{}, n)
+		for i := range keys {
+			m[keys[i].(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i := range keys {
+		switch keys[i].(type) {
+		case []interface{}, map[string]interface{}, map[interface{}]interface{}, []byte:
+			return nil, fmt.Errorf("msgpack: unhashable map key %T", keys[i])
+		}
+		m[keys[i]] = values[i]
+	}
+	return m, nil
+}
+
+var binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+func mpTypeError(src interface{}, dst reflect.Value) error {
+	return fmt.Errorf("msgpack: cannot unmarshal %T into %s", src, dst.Type())
+}
+
+func mpAssign(dst reflect.Value, src interface{}) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return mpAssign(dst.Elem(), src)
+	}
+
+	if bs, ok := src.([]byte); ok && reflect.PtrTo(dst.Type()).Implements(binaryUnmarshalerType) {
+		return dst.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(bs)
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mpTypeError(src, dst)
+		}
+		dst.Set(reflect.ValueOf(src))
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return mpTypeError(src, dst)
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch t := src.(type) {
+		case int64:
+			n = t
+		case uint64:
+			if t > math.MaxInt64 {
+				return mpTypeError(src, dst)
+			}
+			n = int64(t)
+		default:
+			return mpTypeError(src, dst)
+		}
+		if dst.OverflowInt(n) {
+			return mpTypeError(src, dst)
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch t := src.(type) {
+		case int64:
+			if t < 0 {
+				return mpTypeError(src, dst)
+			}
+			n = uint64(t)
+		case uint64:
+			n = t
+		default:
+			return mpTypeError(src, dst)
+		}
+		if dst.OverflowUint(n) {
+			return mpTypeError(src, dst)
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch t := src.(type) {
+		case float64:
+			dst.SetFloat(t)
+		case int64:
+			dst.SetFloat(float64(t))
+		case uint64:
+			dst.SetFloat(float64(t))
+		default:
+			return mpTypeError(src, dst)
+		}
+	case reflect.String:
+		switch t := src.(type) {
+		case string:
+			dst.SetString(t)
+		case []byte:
+			dst.SetString(string(t))
+		default:
+			return mpTypeError(src, dst)
+		}
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch t := src.(type) {
+			case []byte:
+				dst.SetBytes(t)
+				return nil
+			case string:
+				dst.SetBytes([]byte(t))
+				return nil
+			}
+		}
+		a, ok := src.([]interface{})
+		if !ok {
+			return mpTypeError(src, dst)
+		}
+		s := reflect.MakeSlice(dst.Type(), len(a), len(a))
+		for i := range a {
+			if err := mpAssign(s.Index(i), a[i]); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		a, ok := src.([]interface{})
+		if !ok {
+			if bs, isBytes := src.([]byte); isBytes && dst.Type().Elem().Kind() == reflect.Uint8 {
+				reflect.Copy(dst, reflect.ValueOf(bs))
+				return nil
+			}
+			return mpTypeError(src, dst)
+		}
+		for i := 0; i < dst.Len() && i < len(a); i++ {
+			if err := mpAssign(dst.Index(i), a[i]); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m := reflect.MakeMap(dst.Type())
+		set := func(k, v interface{}) error {
+			key := reflect.New(dst.Type().Key()).Elem()
+			if err := mpAssign(key, k); err != nil {
+				return err
+			}
+			value := reflect.New(dst.Type().Elem()).Elem()
+			if err := mpAssign(value, v); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+			return nil
+		}
+		switch t := src.(type) {
+		case map[string]interface{}:
+			for k, v := range t {
+				if err := set(k, v); err != nil {
+					return err
+				}
+			}
+		case map[interface{}]interface{}:
+			for k, v := range t {
+				if err := set(k, v); err != nil {
+					return err
+				}
+			}
+		default:
+			return mpTypeError(src, dst)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return mpTypeError(src, dst)
+		}
+		for _, f := range mpStructFields(dst.Type()) {
+			v, ok := m[f.name]
+			if !ok {
+				continue
+			}
+			if err := mpAssign(dst.FieldByIndex(f.index), v); err != nil {
+				return err
+			}
+		}
+	default:
+		return mpTypeError(src, dst)
+	}
+	return nil
+}
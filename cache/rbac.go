@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// -----------------------------------------------------------------------------
+// rbac roles cache
+// roles of user loaded from db cached in `roles.<user id>`, removed when roles changed.
+// -----------------------------------------------------------------------------
+
+func rbacRolesKey(userID string) string {
+	return composeKey2(rbacModule, "roles."+userID)
+}
+
+// RBACRolesSet cache roles of user, empty roles cached too
+func RBACRolesSet(userID string, roles []string, expire time.Duration) (err error) {
+	defer observe("RBACRolesSet", time.Now(), &err)
+	if roles == nil {
+		roles = []string{}
+	}
+	bs, err := encodeObject(roles)
+	if err != nil {
+		return err
+	}
+	return redisClient.Set(rbacRolesKey(userID), bs, expire).Err()
+}
+
+// RBACRolesGet cached roles of user, NotExist if not cached. never served from breaker
+// near-cache (roles revoked while circuit open would be granted), ErrCircuitOpen returned.
+func RBACRolesGet(userID string) (roles []string, err error) {
+	defer observeGet("RBACRolesGet", time.Now(), &err)
+	bs, err := redisClient.Get(rbacRolesKey(userID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, NotExist
+		}
+		return nil, err
+	}
+	if err := decodeObject(bs, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// RBACRolesDel remove cached roles of user
+func RBACRolesDel(userID string) (err error) {
+	defer observe("RBACRolesDel", time.Now(), &err)
+	return redisClient.Del(rbacRolesKey(userID)).Err()
+}
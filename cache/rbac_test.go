@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBACRoles(t *testing.T) {
+	userID := "TestRBACRoles"
+	defer RBACRolesDel(userID)
+
+	_, err := RBACRolesGet(userID)
+	assert.Equal(t, NotExist, err)
+
+	require.Nil(t, RBACRolesSet(userID, nil, time.Minute))
+	roles, err := RBACRolesGet(userID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{}, roles)
+
+	require.Nil(t, RBACRolesSet(userID, []string{"viewer", "clerk"}, time.Minute))
+	roles, err = RBACRolesGet(userID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"viewer", "clerk"}, roles)
+
+	keys, err := ScanKeys(ModuleRBAC, "roles.*")
+	require.Nil(t, err)
+	assert.Contains(t, keys, "roles."+userID)
+
+	require.Nil(t, RBACRolesDel(userID))
+	_, err = RBACRolesGet(userID)
+	assert.Equal(t, NotExist, err)
+}
+
+func TestRBACRolesBreakerOpen(t *testing.T) {
+	userID := "TestRBACRolesBreakerOpen"
+	EnableBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute, NearCacheSize: 8})
+	defer DisableBreaker()
+	defer RBACRolesDel(userID)
+
+	require.Nil(t, RBACRolesSet(userID, []string{"admin"}, time.Minute))
+	roles, err := RBACRolesGet(userID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"admin"}, roles)
+
+	// trip, roles revoked meanwhile must not be served from near-cache
+	b := loadBreaker()
+	_, ok := b.near.get(rbacRolesKey(userID))
+	require.True(t, ok)
+	a, _ := b.allow()
+	b.done(a, true)
+	_, err = RBACRolesGet(userID)
+	assert.Equal(t, ErrCircuitOpen, err)
+}
@@ -1,19 +1,20 @@
 package cache
 
 import (
-	"encoding/json"
 	"fmt"
 	"strconv"
 	"sync"
 	"time"
 
 	redis "github.com/go-redis/redis/v7"
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
 )
 
 var (
 	NotExist                = fmt.Errorf("key not exist")
 	CounterZero             = fmt.Errorf("counter zero")
 	ErrUnLockTicketNotMatch = fmt.Errorf("unlock ticket not match")
+	ErrLockNotHeld          = fmt.Errorf("lock not held")
 )
 
 var (
@@ -23,6 +24,15 @@ var (
 	mqModule      string = "_mq_"
 	counterModule string = "_counter_"
 	setModule     string = "_set_"
+	semModule     string = "_sem_"
+	cronModule    string = "_cron_"
+	idemModule    string = "_idem_"
+	sessionModule string = "_session_"
+	vcodeModule   string = "_vcode_"
+	windowModule  string = "_window_"
+	geoModule     string = "_geo_"
+	revokeModule  string = "_revoke_"
+	rbacModule    string = "_rbac_"
 
 	once        sync.Once
 	redisClient *redis.Client = nil
@@ -36,6 +46,7 @@ func InitCache(app string, addr string, password string, db int) error {
 			Password: password,
 			DB:       db,
 		})
+		client.AddHook(breakerHook{})
 		appName = app
 		redisClient = client
 	})
@@ -75,11 +86,13 @@ func composeKey2(module string, key string) string {
 // common built-in type wrapper
 // ----------------------------------------------------------------------------
 
-// SetObject set object, object must be json marshaled
-func SetObject(key string, value interface{}, expire time.Duration) error {
+// SetObject set object, object must be marshaled by codec (default json),
+// see SetObjectCodec/SetObjectCompression
+func SetObject(key string, value interface{}, expire time.Duration) (err error) {
+	defer observe("SetObject", time.Now(), &err)
 	realKey := composeKey(key)
 
-	bs, err := json.Marshal(value)
+	bs, err := encodeObject(value)
 	if err != nil {
 		return err
 	}
@@ -92,11 +105,12 @@ func SetObject(key string, value interface{}, expire time.Duration) error {
 	return nil
 }
 
-// GetObject get object, object must be json unmarshaled
-func GetObject(key string, value interface{}) error {
+// GetObject get object, object must be unmarshaled by the codec it written
+func GetObject(key string, value interface{}) (err error) {
+	defer observeGet("GetObject", time.Now(), &err)
 	realKey := composeKey(key)
 
-	bs, err := redisClient.Get(realKey).Bytes()
+	bs, err := getBytes(realKey)
 	if err != nil {
 		if err == redis.Nil {
 			return NotExist
@@ -104,7 +118,7 @@ func GetObject(key string, value interface{}) error {
 		return err
 	}
 
-	if err := json.Unmarshal(bs, value); err != nil {
+	if err := decodeObject(bs, value); err != nil {
 		return err
 	}
 
@@ -115,6 +129,8 @@ func GetObject(key string, value interface{}) error {
 // - The command returns -1 if the key exists but has no associated expire.
 // - The command returns -2 if the key does not exist.
 func TTL(key string) time.Duration {
+	var err error
+	defer observe("TTL", time.Now(), &err)
 	realKey := composeKey(key)
 	d, err := redisClient.TTL(realKey).Result()
 	if err != nil {
@@ -127,6 +143,8 @@ func TTL(key string) time.Duration {
 // - The command returns -1 if the key exists but has no associated expire.
 // - The command returns -2 if the key does not exist.
 func PTTL(key string) time.Duration {
+	var err error
+	defer observe("PTTL", time.Now(), &err)
 	realKey := composeKey(key)
 	d, err := redisClient.PTTL(realKey).Result()
 	if err != nil {
@@ -135,16 +153,18 @@ func PTTL(key string) time.Duration {
 	return d
 }
 
-func Del(key string) error {
+func Del(key string) (err error) {
+	defer observe("Del", time.Now(), &err)
 	realKey := composeKey(key)
-	_, err := redisClient.Del(realKey).Result()
+	_, err = redisClient.Del(realKey).Result()
 	return err
 }
 
-func SetString(key string, value string, expire time.Duration) error {
+func SetString(key string, value string, expire time.Duration) (err error) {
+	defer observe("SetString", time.Now(), &err)
 	realKey := composeKey(key)
 
-	_, err := redisClient.Set(realKey, []byte(value), expire).Result()
+	_, err = redisClient.Set(realKey, []byte(value), expire).Result()
 	if err != nil {
 		return err
 	}
@@ -152,10 +172,11 @@ func SetString(key string, value string, expire time.Duration) error {
 	return nil
 }
 
-func GetString(key string) (string, error) {
+func GetString(key string) (s string, err error) {
+	defer observeGet("GetString", time.Now(), &err)
 	realKey := composeKey(key)
 
-	bs, err := redisClient.Get(realKey).Bytes()
+	bs, err := getBytes(realKey)
 	if err != nil {
 		if err == redis.Nil {
 			return "", NotExist
@@ -169,10 +190,11 @@ func SetInt(key string, value int, expire time.Duration) error {
 	return SetString(key, strconv.Itoa(value), expire)
 }
 
-func GetInt(key string) (int, error) {
+func GetInt(key string) (value int, err error) {
+	defer observeGet("GetInt", time.Now(), &err)
 	realKey := composeKey(key)
 
-	value, err := redisClient.Get(realKey).Int()
+	value, err = redisClient.Get(realKey).Int()
 	if err != nil {
 		if err == redis.Nil {
 			return 0, NotExist
@@ -187,10 +209,11 @@ func SetInt64(key string, value int64, expire time.Duration) error {
 	return SetString(key, strconv.FormatInt(value, 10), expire)
 }
 
-func GetInt64(key string) (int64, error) {
+func GetInt64(key string) (value int64, err error) {
+	defer observeGet("GetInt64", time.Now(), &err)
 	realKey := composeKey(key)
 
-	value, err := redisClient.Get(realKey).Int64()
+	value, err = redisClient.Get(realKey).Int64()
 	if err != nil {
 		if err == redis.Nil {
 			return 0, NotExist
@@ -204,10 +227,11 @@ func SetFloat64(key string, value float64, expire time.Duration) error {
 	return SetString(key, strconv.FormatFloat(value, 'f', -1, 64), expire)
 }
 
-func GetFloat64(key string) (float64, error) {
+func GetFloat64(key string) (value float64, err error) {
+	defer observeGet("GetFloat64", time.Now(), &err)
 	realKey := composeKey(key)
 
-	value, err := redisClient.Get(realKey).Float64()
+	value, err = redisClient.Get(realKey).Float64()
 	if err != nil {
 		if err == redis.Nil {
 			return 0, NotExist
@@ -244,28 +268,49 @@ func GetBool(key string) (bool, error) {
 
 // TryLock if lock failure, max wait "timeout" duration (retry lock)
 func TryLock(name string, ticket string, expire time.Duration, timeout time.Duration) bool {
+	start := time.Now()
 	t := time.NewTimer(timeout)
-	for {
+	defer t.Stop()
+	for i := 0; ; i++ {
 		select {
 		case <-t.C:
+			observeLock(name, LockTimeout, time.Since(start))
 			return false
 		default:
-			result := Lock(name, ticket, expire)
+			result := lock(name, ticket, expire)
 			if result {
+				observeLock(name, LockAcquired, time.Since(start))
 				return true
 			}
+			if i == 0 {
+				observeLock(name, LockContended, time.Since(start))
+			}
 			time.Sleep(10 * time.Millisecond)
 		}
 	}
 }
 
 func Lock(name string, ticket string, expire time.Duration) bool {
+	start := time.Now()
+	result := lock(name, ticket, expire)
+	if result {
+		observeLock(name, LockAcquired, time.Since(start))
+	} else {
+		observeLock(name, LockContended, time.Since(start))
+	}
+	return result
+}
+
+func lock(name string, ticket string, expire time.Duration) bool {
+	var err error
+	defer observe("Lock", time.Now(), &err)
 	lockKey := composeKey2(disLockModule, name)
-	result := redisClient.SetNX(lockKey, ticket, expire).Val()
+	result, err := redisClient.SetNX(lockKey, ticket, expire).Result()
 	return result
 }
 
-func UnLock(name string, ticket string) error {
+func UnLock(name string, ticket string) (err error) {
+	defer observe("UnLock", time.Now(), &err)
 	lockKey := composeKey2(disLockModule, name)
 	v, err := redisClient.Get(lockKey).Result()
 	if err != nil {
@@ -275,25 +320,49 @@ func UnLock(name string, ticket string) error {
 	// just can unlock itself
 	if v == ticket {
 		_, err := redisClient.Del(lockKey).Result()
+		if err == nil {
+			observeLock(name, LockReleased, 0)
+		}
 		return err
 	} else {
 		return ErrUnLockTicketNotMatch
 	}
 }
 
+// RenewLock reset lock expire, only the ticket holder can renew,
+// ErrLockNotHeld if lock expired or held by others
+func RenewLock(name string, ticket string, expire time.Duration) (err error) {
+	defer observe("RenewLock", time.Now(), &err)
+	lockKey := composeKey2(disLockModule, name)
+
+	result, err := redisClient.Eval(scripts.RenewLock, []string{lockKey}, ticket, expire.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
 // -----------------------------------------------------------------------------
 // message queue
 // -----------------------------------------------------------------------------
 
-func MQPush(key string, bs []byte) error {
+func MQPush(key string, bs []byte) (err error) {
+	defer observe("MQPush", time.Now(), &err)
 	mqKey := composeKey2(mqModule, key)
-	_, err := redisClient.RPush(mqKey, bs).Result()
+	depth, err := redisClient.RPush(mqKey, bs).Result()
+	if err == nil {
+		observeMQDepth(key, depth)
+	}
 	return err
 }
 
-func MQPop(key string) ([]byte, error) {
+func MQPop(key string) (bs []byte, err error) {
+	defer observe("MQPop", time.Now(), &err)
 	mqKey := composeKey2(mqModule, key)
-	bs, err := redisClient.LPop(mqKey).Bytes()
+	bs, err = redisClient.LPop(mqKey).Bytes()
 	if err == redis.Nil {
 		return nil, NotExist
 	}
@@ -301,7 +370,8 @@ func MQPop(key string) ([]byte, error) {
 }
 
 // MQBlockPop block pop, in comparison, block pop fast than polling pop
-func MQBlockPop(key string, timeout time.Duration) ([]byte, error) {
+func MQBlockPop(key string, timeout time.Duration) (bs []byte, err error) {
+	defer observe("MQBlockPop", time.Now(), &err)
 	// timeout min value is 1s
 	if timeout.Seconds() < 1 {
 		timeout = time.Second
@@ -320,16 +390,21 @@ func MQBlockPop(key string, timeout time.Duration) ([]byte, error) {
 }
 
 func MQLen(key string) int64 {
+	var err error
+	defer observe("MQLen", time.Now(), &err)
 	mqKey := composeKey2(mqModule, key)
 	count, err := redisClient.LLen(mqKey).Result()
 	if err != nil {
 		return 0
 	}
+	observeMQDepth(key, count)
 	return count
 }
 
 // MQDel delete mq return count, mq key can not use `Del` delete, they have different compose method
 func MQDel(key string) int64 {
+	var err error
+	defer observe("MQDel", time.Now(), &err)
 	mqKey := composeKey2(mqModule, key)
 	count, err := redisClient.Del(mqKey).Result()
 	if err != nil {
@@ -343,52 +418,44 @@ func MQDel(key string) int64 {
 // -----------------------------------------------------------------------------
 
 // CounterIncr atomic increment 1, return inc result value
-func CounterIncr(key string, expire time.Duration) (int64, error) {
+func CounterIncr(key string, expire time.Duration) (n int64, err error) {
+	defer observe("CounterIncr", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
 
 	pipe := redisClient.TxPipeline()
 	incr := pipe.Incr(aKey)
 	pipe.Expire(aKey, expire)
-	_, err := pipe.Exec()
+	_, err = pipe.Exec()
 
 	return incr.Val(), err
 }
 
 // CounterIncrBy atomic increment n, return incrby result value
-func CounterIncrBy(key string, n int64, expire time.Duration) (int64, error) {
+func CounterIncrBy(key string, n int64, expire time.Duration) (v int64, err error) {
+	defer observe("CounterIncrBy", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
 
 	pipe := redisClient.TxPipeline()
 	incr := pipe.IncrBy(aKey, n)
 	pipe.Expire(aKey, expire)
-	_, err := pipe.Exec()
+	_, err = pipe.Exec()
 
 	return incr.Val(), err
 }
 
 // CounterDecr atomic decrement 1, return decr result value
-func CounterDecr(key string) (int64, error) {
+func CounterDecr(key string) (n int64, err error) {
+	defer observe("CounterDecr", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
 	return redisClient.Decr(aKey).Result()
 }
 
 // CounterDecrMinZero atomic decrement, min value is 0
-func CounterDecrMinZero(key string) (int64, error) {
+func CounterDecrMinZero(key string) (n int64, err error) {
+	defer observe("CounterDecrMinZero", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
 
-	script := `
-local v = redis.call("GET", KEYS[1])
-if v == false then
-   return -2
-end
-
-if tonumber(v) > 0 then
-   return redis.call("DECR", KEYS[1])
-else
-   return -1
-end
-	`
-	result, err := redisClient.Eval(script, []string{aKey}).Int64()
+	result, err := redisClient.Eval(scripts.CounterDecrMinZero, []string{aKey}).Int64()
 	if err != nil {
 		return 0, err
 	}
@@ -402,28 +469,33 @@ end
 }
 
 // CounterDecrBy atomic decrement n, return decr result value
-func CounterDecrBy(key string, n int64) (int64, error) {
+func CounterDecrBy(key string, n int64) (v int64, err error) {
+	defer observe("CounterDecrBy", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
 	return redisClient.DecrBy(aKey, n).Result()
 }
 
 // CounterReset reset counter to 0
-func CounterReset(key string, expire time.Duration) error {
+func CounterReset(key string, expire time.Duration) (err error) {
+	defer observe("CounterReset", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
-	_, err := redisClient.Set(aKey, "0", expire).Result()
+	_, err = redisClient.Set(aKey, "0", expire).Result()
 	return err
 }
 
 // CounterDel delete counter
 func CounterDel(key string) {
+	var err error
+	defer observe("CounterDel", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
-	redisClient.Del(aKey)
+	err = redisClient.Del(aKey).Err()
 }
 
 // CounterGet get counter value
-func CounterGet(key string) (int64, error) {
+func CounterGet(key string) (value int64, err error) {
+	defer observeGet("CounterGet", time.Now(), &err)
 	aKey := composeKey2(counterModule, key)
-	value, err := redisClient.Get(aKey).Int64()
+	value, err = redisClient.Get(aKey).Int64()
 	if err != nil {
 		if err == redis.Nil {
 			return 0, NotExist
@@ -439,9 +511,10 @@ func CounterGet(key string) (int64, error) {
 // -----------------------------------------------------------------------------
 
 // SSMembers get all members slice
-func SSMembers(key string) ([]string, error) {
+func SSMembers(key string) (values []string, err error) {
+	defer observe("SSMembers", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
-	values, err := redisClient.SMembers(aKey).Result()
+	values, err = redisClient.SMembers(aKey).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, NotExist
@@ -452,29 +525,33 @@ func SSMembers(key string) ([]string, error) {
 }
 
 // SSAdd add members to Set
-func SSAdd(key string, members ...string) error {
+func SSAdd(key string, members ...string) (err error) {
+	defer observe("SSAdd", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	t := []interface{}{}
 	for _, v := range members {
 		t = append(t, v)
 	}
-	_, err := redisClient.SAdd(aKey, t...).Result()
+	_, err = redisClient.SAdd(aKey, t...).Result()
 	return err
 }
 
 // SSRem remove members from Set
-func SSRem(key string, members ...string) error {
+func SSRem(key string, members ...string) (err error) {
+	defer observe("SSRem", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	t := []interface{}{}
 	for _, v := range members {
 		t = append(t, v)
 	}
-	_, err := redisClient.SRem(aKey, t...).Result()
+	_, err = redisClient.SRem(aKey, t...).Result()
 	return err
 }
 
 // SSCount get member count
 func SSCount(key string) int64 {
+	var err error
+	defer observe("SSCount", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	count, err := redisClient.SCard(aKey).Result()
 	if err != nil {
@@ -485,6 +562,8 @@ func SSCount(key string) int64 {
 
 // SSIsMember check set if include member
 func SSIsMember(key string, member string) bool {
+	var err error
+	defer observe("SSIsMember", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	ok, err := redisClient.SIsMember(aKey, member).Result()
 	if err != nil {
@@ -495,6 +574,8 @@ func SSIsMember(key string, member string) bool {
 
 // SSRandomN random get N members
 func SSRandomN(key string, count int64) []string {
+	var err error
+	defer observe("SSRandomN", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	values, err := redisClient.SRandMemberN(aKey, count).Result()
 	if err != nil {
@@ -504,14 +585,18 @@ func SSRandomN(key string, count int64) []string {
 }
 
 func SSDelete(key string) {
+	var err error
+	defer observe("SSDelete", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
-	redisClient.Del(aKey)
+	err = redisClient.Del(aKey).Err()
 }
 
 // SS_TTL seconds resolution
 // - The command returns -1 if the key exists but has no associated expire.
 // - The command returns -2 if the key does not exist.
 func SS_TTL(key string) time.Duration {
+	var err error
+	defer observe("SS_TTL", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	d, err := redisClient.TTL(aKey).Result()
 	if err != nil {
@@ -524,6 +609,8 @@ func SS_TTL(key string) time.Duration {
 // - The command returns -1 if the key exists but has no associated expire.
 // - The command returns -2 if the key does not exist.
 func SS_PTTL(key string) time.Duration {
+	var err error
+	defer observe("SS_PTTL", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	d, err := redisClient.PTTL(aKey).Result()
 	if err != nil {
@@ -532,7 +619,115 @@ func SS_PTTL(key string) time.Duration {
 	return d
 }
 
-func SSExpire(key string, d time.Duration) error {
+func SSExpire(key string, d time.Duration) (err error) {
+	defer observe("SSExpire", time.Now(), &err)
 	aKey := composeKey2(setModule, key)
 	return redisClient.Expire(aKey, d).Err()
 }
+
+func ssKeys(keys []string) []string {
+	aKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		aKeys = append(aKeys, composeKey2(setModule, key))
+	}
+	return aKeys
+}
+
+// SSUnion members of the union of all sets
+func SSUnion(keys ...string) (values []string, err error) {
+	defer observe("SSUnion", time.Now(), &err)
+	return redisClient.SUnion(ssKeys(keys)...).Result()
+}
+
+// SSInter members of the intersection of all sets
+func SSInter(keys ...string) (values []string, err error) {
+	defer observe("SSInter", time.Now(), &err)
+	return redisClient.SInter(ssKeys(keys)...).Result()
+}
+
+// SSDiff members of the first set not in all successive sets
+func SSDiff(keys ...string) (values []string, err error) {
+	defer observe("SSDiff", time.Now(), &err)
+	return redisClient.SDiff(ssKeys(keys)...).Result()
+}
+
+// SSUnionStore store union of all sets in `dst` (overwritten), return member count of `dst`
+func SSUnionStore(dst string, keys ...string) (n int64, err error) {
+	defer observe("SSUnionStore", time.Now(), &err)
+	return redisClient.SUnionStore(composeKey2(setModule, dst), ssKeys(keys)...).Result()
+}
+
+// SSInterStore store intersection of all sets in `dst` (overwritten), return member count of `dst`
+func SSInterStore(dst string, keys ...string) (n int64, err error) {
+	defer observe("SSInterStore", time.Now(), &err)
+	return redisClient.SInterStore(composeKey2(setModule, dst), ssKeys(keys)...).Result()
+}
+
+// SSDiffStore store difference of all sets in `dst` (overwritten), return member count of `dst`
+func SSDiffStore(dst string, keys ...string) (n int64, err error) {
+	defer observe("SSDiffStore", time.Now(), &err)
+	return redisClient.SDiffStore(composeKey2(setModule, dst), ssKeys(keys)...).Result()
+}
+
+// SSPop remove and return a random member, NotExist if set is empty
+func SSPop(key string) (value string, err error) {
+	defer observeGet("SSPop", time.Now(), &err)
+	aKey := composeKey2(setModule, key)
+	value, err = redisClient.SPop(aKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", NotExist
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+// SSPopN remove and return at most `count` random members
+func SSPopN(key string, count int64) (values []string, err error) {
+	defer observe("SSPopN", time.Now(), &err)
+	aKey := composeKey2(setModule, key)
+	values, err = redisClient.SPopN(aKey, count).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return values, nil
+}
+
+// SSMove move member from `src` to `dst` atomically, false if member not in `src`
+func SSMove(src string, dst string, member string) (ok bool, err error) {
+	defer observe("SSMove", time.Now(), &err)
+	return redisClient.SMove(composeKey2(setModule, src), composeKey2(setModule, dst), member).Result()
+}
+
+// SSScanCursor iterate members incrementally, start with cursor 0, iteration finished when next cursor is 0.
+// `match` glob-style member pattern, "" for all; `count` hint of members per call.
+// a member may be returned multiple times (set modified while iterating).
+func SSScanCursor(key string, cursor uint64, match string, count int64) (values []string, next uint64, err error) {
+	defer observe("SSScanCursor", time.Now(), &err)
+	aKey := composeKey2(setModule, key)
+	return redisClient.SScan(aKey, cursor, match, count).Result()
+}
+
+// SSScan iterate all members matching `match` ("" for all), stop if `fn` return error
+func SSScan(key string, match string, fn func(member string) error) error {
+	var cursor uint64
+	for {
+		values, next, err := SSScanCursor(key, cursor, match, 100)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -9,23 +9,37 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zhangjie2012/cbl-go/cache/cachetest"
 )
 
 func TestMain(m *testing.M) {
 	var (
 		name      string = "cblcache"
-		redisAddr string = "localhost:6379"
+		redisAddr string = os.Getenv("CBL_TEST_REDIS_ADDR") // test with real redis, e.g. localhost:6379
 		password  string = ""
 		db        int    = 0
 	)
 
+	// hermetic by default
+	if redisAddr == "" {
+		srv, err := cachetest.NewServer()
+		if err != nil {
+			fmt.Printf("fake redis start failure, err=%s", err)
+			return
+		}
+		redisAddr = srv.Addr()
+	}
+
 	if err := InitCache(name, redisAddr, password, db); err != nil {
 		fmt.Printf("redis init failure, err=%s", err)
 		return
 	}
-	defer CloseCache()
 
-	os.Exit(m.Run())
+	ec := m.Run()
+
+	CloseCache()
+
+	os.Exit(ec)
 }
 
 func TestSetGetObject(t *testing.T) {
@@ -508,3 +522,84 @@ func TestSetS(t *testing.T) {
 
 	SSDelete(key)
 }
+
+func TestSetSAlgebra(t *testing.T) {
+	var (
+		a, b, dst = "TestSetSAlgebra.a", "TestSetSAlgebra.b", "TestSetSAlgebra.dst"
+	)
+	defer SSDelete(a)
+	defer SSDelete(b)
+	defer SSDelete(dst)
+
+	require.Nil(t, SSAdd(a, "1", "2", "3"))
+	require.Nil(t, SSAdd(b, "2", "3", "4"))
+
+	values, err := SSUnion(a, b)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, values)
+
+	values, err = SSInter(a, b)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"2", "3"}, values)
+
+	values, err = SSDiff(a, b)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1"}, values)
+
+	n, err := SSUnionStore(dst, a, b)
+	require.Nil(t, err)
+	assert.EqualValues(t, 4, n)
+	n, err = SSInterStore(dst, a, b)
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, n)
+	n, err = SSDiffStore(dst, a, b)
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, n)
+
+	ok, err := SSMove(a, b, "1")
+	require.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = SSMove(a, b, "1")
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.True(t, SSIsMember(b, "1"))
+
+	v, err := SSPop(a)
+	require.Nil(t, err)
+	assert.Contains(t, []string{"2", "3"}, v)
+	values, err = SSPopN(a, 10)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(values))
+	_, err = SSPop(a)
+	assert.Equal(t, NotExist, err)
+}
+
+func TestSetSScan(t *testing.T) {
+	key := "TestSetSScan"
+	defer SSDelete(key)
+
+	members := []string{}
+	for i := 0; i < 300; i++ {
+		members = append(members, fmt.Sprintf("m%d", i))
+	}
+	require.Nil(t, SSAdd(key, members...))
+
+	seen := map[string]bool{}
+	err := SSScan(key, "", func(member string) error {
+		seen[member] = true
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 300, len(seen))
+
+	matched := 0
+	err = SSScan(key, "m1?", func(member string) error {
+		matched++
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 10, matched)
+
+	stop := fmt.Errorf("stop")
+	assert.Equal(t, stop, SSScan(key, "", func(string) error { return stop }))
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// -----------------------------------------------------------------------------
+// token revocation list
+// revoked token ids stored in `t.<id>`, subject (user) revoked time stored in
+// `s.<subject>` (tokens issued before it are revoked), expired with the tokens.
+// -----------------------------------------------------------------------------
+
+func revokeTokenKey(id string) string {
+	return composeKey2(revokeModule, "t."+id)
+}
+
+func revokeSubjectKey(subject string) string {
+	return composeKey2(revokeModule, "s."+subject)
+}
+
+// RevokeToken revoke token `id` for `ttl` (remaining lifetime of token) atomically,
+// false if already revoked, e.g. refresh token rotation only one request wins
+func RevokeToken(id string, ttl time.Duration) (revoked bool, err error) {
+	defer observe("RevokeToken", time.Now(), &err)
+	return redisClient.SetNX(revokeTokenKey(id), "1", ttl).Result()
+}
+
+// TokenRevoked token `id` revoked
+func TokenRevoked(id string) (revoked bool, err error) {
+	defer observe("TokenRevoked", time.Now(), &err)
+	n, err := redisClient.Exists(revokeTokenKey(id)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RevokeSubject revoke all tokens of subject issued before `at` (unix milliseconds precision),
+// keep `ttl` (max lifetime of tokens)
+func RevokeSubject(subject string, at time.Time, ttl time.Duration) (err error) {
+	defer observe("RevokeSubject", time.Now(), &err)
+	return redisClient.Set(revokeSubjectKey(subject), at.UnixNano()/int64(time.Millisecond), ttl).Err()
+}
+
+// SubjectRevokedAt revoked time of subject, NotExist if not revoked
+func SubjectRevokedAt(subject string) (at time.Time, err error) {
+	defer observeGet("SubjectRevokedAt", time.Now(), &err)
+	ms, err := redisClient.Get(revokeSubjectKey(subject)).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return time.Time{}, NotExist
+		}
+		return time.Time{}, err
+	}
+	if ms < 1e12 { // unix seconds recorded by older versions
+		return time.Unix(ms, 0), nil
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
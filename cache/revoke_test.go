@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	id := "TestRevokeToken"
+	defer C().Del(revokeTokenKey(id))
+
+	revoked, err := TokenRevoked(id)
+	require.Nil(t, err)
+	assert.False(t, revoked)
+
+	ok, err := RevokeToken(id, time.Minute)
+	require.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = RevokeToken(id, time.Minute) // only once
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	revoked, err = TokenRevoked(id)
+	require.Nil(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevokeSubject(t *testing.T) {
+	subject := "TestRevokeSubject"
+	defer C().Del(revokeSubjectKey(subject))
+
+	_, err := SubjectRevokedAt(subject)
+	assert.Equal(t, NotExist, err)
+
+	now := time.Now()
+	require.Nil(t, RevokeSubject(subject, now, time.Minute))
+	at, err := SubjectRevokedAt(subject)
+	require.Nil(t, err)
+	assert.Equal(t, now.Truncate(time.Millisecond).UnixNano(), at.UnixNano())
+
+	require.Nil(t, C().Set(revokeSubjectKey(subject), now.Unix(), time.Minute).Err())
+	at, err = SubjectRevokedAt(subject)
+	require.Nil(t, err)
+	assert.Equal(t, now.Unix(), at.Unix())
+
+	keys := []string{}
+	Scan(ModuleRevoke, "*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	assert.Contains(t, keys, "s."+subject)
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+// Module key namespace, every module keys compose as `appname:module.key`,
+// plain keys (Set*/Get*) compose as `appname:key`.
+type Module string
+
+var (
+	ModulePlain   Module = ""
+	ModuleDisLock Module = Module(disLockModule)
+	ModuleMQ      Module = Module(mqModule)
+	ModuleCounter Module = Module(counterModule)
+	ModuleSet     Module = Module(setModule)
+	ModuleSem     Module = Module(semModule)
+	ModuleCron    Module = Module(cronModule)
+	ModuleIdem    Module = Module(idemModule)
+	ModuleSession Module = Module(sessionModule)
+	ModuleVCode   Module = Module(vcodeModule)
+	ModuleWindow  Module = Module(windowModule)
+	ModuleGeo     Module = Module(geoModule)
+	ModuleRevoke  Module = Module(revokeModule)
+	ModuleRBAC    Module = Module(rbacModule)
+
+	// all known modules, plain scan skip keys belong to them
+	modules = []Module{ModuleDisLock, ModuleMQ, ModuleCounter, ModuleSet, ModuleSem, ModuleCron, ModuleIdem, ModuleSession, ModuleVCode, ModuleWindow, ModuleGeo, ModuleRevoke, ModuleRBAC}
+)
+
+// ParseModule parse module from name, accept "plain"/"" and module name
+// with or without underscore, e.g. "mq" or "_mq_"
+func ParseModule(name string) (Module, bool) {
+	name = strings.Trim(name, "_")
+	if name == "" || name == "plain" {
+		return ModulePlain, true
+	}
+	for _, m := range modules {
+		if strings.Trim(string(m), "_") == name {
+			return m, true
+		}
+	}
+	return ModulePlain, false
+}
+
+// String module readable name
+func (m Module) String() string {
+	if m == ModulePlain {
+		return "plain"
+	}
+	return strings.Trim(string(m), "_")
+}
+
+func (m Module) prefix() string {
+	if m == ModulePlain {
+		return composeKey("")
+	}
+	return composeKey2(string(m), "")
+}
+
+// realKey compose key like the module's own methods
+func (m Module) realKey(key string) string {
+	return m.prefix() + key
+}
+
+// escapeGlob escape redis glob special characters, make prefix matched literally
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// belongModule plain key `appname:_mq_.xxx` actually belong to other module
+func belongModule(key string) bool {
+	for _, m := range modules {
+		if strings.HasPrefix(key, string(m)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+const scanCount int64 = 100
+
+// Scan iterate keys of module matched glob `pattern` via SCAN (not block server like KEYS),
+// `fn` receive key without app/module prefix (can be used by module's methods directly),
+// stop iterate when `fn` return error, and return the error.
+//
+// Note: SCAN guarantee a key exists during full iteration will be returned, but may return
+// a key more than once.
+func Scan(module Module, pattern string, fn func(key string) error) error {
+	if pattern == "" {
+		pattern = "*"
+	}
+	prefix := module.prefix()
+	match := escapeGlob(prefix) + pattern
+
+	var cursor uint64
+	for {
+		keys, next, err := redisClient.Scan(cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			key := strings.TrimPrefix(k, prefix)
+			if module == ModulePlain && belongModule(key) {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// ScanKeys get all keys of module matched `pattern`, deduplicated
+func ScanKeys(module Module, pattern string) ([]string, error) {
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	err := Scan(module, pattern, func(key string) error {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Purge delete keys of module matched `pattern`, UNLINK every `batch` keys
+// (reclaim memory asynchronous, not block server), return deleted count.
+// e.g. wipe all `yangyin.*`:
+//
+//	cache.Purge(cache.ModulePlain, "yangyin.*", 0)
+func Purge(module Module, pattern string, batch int) (int64, error) {
+	if batch <= 0 {
+		batch = int(scanCount)
+	}
+
+	var total int64
+	buf := make([]string, 0, batch)
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		n, err := redisClient.Unlink(buf...).Result()
+		if err != nil {
+			return err
+		}
+		total += n
+		buf = buf[:0]
+		return nil
+	}
+
+	err := Scan(module, pattern, func(key string) error {
+		buf = append(buf, module.realKey(key))
+		if len(buf) >= batch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return total, err
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// KeyInfo key inspect result
+type KeyInfo struct {
+	Key     string        `json:"key"`      // key without prefix
+	RealKey string        `json:"real_key"` // redis key
+	Type    string        `json:"type"`     // string/list/set/zset/hash/stream, "none" for not exist
+	TTL     time.Duration `json:"ttl"`      // -1 no associated expire, -2 not exist
+}
+
+// Inspect get key type and ttl
+func Inspect(module Module, key string) (*KeyInfo, error) {
+	realKey := module.realKey(key)
+
+	pipe := redisClient.Pipeline()
+	typ := pipe.Type(realKey)
+	ttl := pipe.PTTL(realKey)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+
+	return &KeyInfo{
+		Key:     key,
+		RealKey: realKey,
+		Type:    typ.Val(),
+		TTL:     ttl.Val(),
+	}, nil
+}
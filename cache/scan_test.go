@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModule(t *testing.T) {
+	m, ok := ParseModule("mq")
+	assert.True(t, ok)
+	assert.Equal(t, ModuleMQ, m)
+
+	m, ok = ParseModule("_counter_")
+	assert.True(t, ok)
+	assert.Equal(t, ModuleCounter, m)
+
+	m, ok = ParseModule("plain")
+	assert.True(t, ok)
+	assert.Equal(t, ModulePlain, m)
+
+	_, ok = ParseModule("whatever")
+	assert.False(t, ok)
+
+	assert.Equal(t, `a\*b\?\[c\]`, escapeGlob("a*b?[c]"))
+}
+
+func TestScanPurge(t *testing.T) {
+	var (
+		err error
+		n   int64
+	)
+
+	for _, k := range []string{"TestScan.1", "TestScan.2", "TestScan.3"} {
+		err = SetString(k, "whatever", 10*time.Second)
+		require.Nil(t, err)
+	}
+	err = MQPush("TestScan.mq", []byte("whatever"))
+	require.Nil(t, err)
+
+	keys, err := ScanKeys(ModulePlain, "TestScan.*")
+	require.Nil(t, err)
+	sort.Strings(keys)
+	assert.EqualValues(t, []string{"TestScan.1", "TestScan.2", "TestScan.3"}, keys)
+
+	keys, err = ScanKeys(ModuleMQ, "TestScan.*")
+	require.Nil(t, err)
+	assert.EqualValues(t, []string{"TestScan.mq"}, keys)
+
+	info, err := Inspect(ModuleMQ, "TestScan.mq")
+	require.Nil(t, err)
+	assert.Equal(t, "list", info.Type)
+	assert.EqualValues(t, -1, info.TTL)
+
+	n, err = Purge(ModulePlain, "TestScan.*", 2)
+	require.Nil(t, err)
+	assert.EqualValues(t, 3, n)
+
+	keys, err = ScanKeys(ModulePlain, "TestScan.*")
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(keys))
+
+	assert.EqualValues(t, 1, MQLen("TestScan.mq"))
+	MQDel("TestScan.mq")
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
+)
+
+// -----------------------------------------------------------------------------
+// distributed semaphore
+// allow max `limit` holders hold semaphore at the same time, holders store in a
+// sorted set, score is holder lease expire at (unix milliseconds).
+// holder not renew lease before expired will be reclaimed automatically.
+//
+// Note: lease based on client clock, make sure clock synchronized.
+// -----------------------------------------------------------------------------
+
+var ErrSemNotHeld = fmt.Errorf("semaphore not held")
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// SemAcquire acquire semaphore `name` (max `limit` holders), `holder` is unique
+// id of the caller like lock ticket, acquire again by the same holder renew its lease.
+// return false when semaphore is full.
+func SemAcquire(name string, holder string, limit int64, lease time.Duration) (ok bool, err error) {
+	defer observe("SemAcquire", time.Now(), &err)
+	semKey := composeKey2(semModule, name)
+
+	now := time.Now()
+	result, err := redisClient.Eval(scripts.SemAcquire, []string{semKey},
+		unixMilli(now), limit, holder, unixMilli(now.Add(lease)), lease.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+// SemTryAcquire if semaphore is full, max wait "timeout" duration (retry acquire)
+func SemTryAcquire(name string, holder string, limit int64, lease time.Duration, timeout time.Duration) (bool, error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			return false, nil
+		default:
+			ok, err := SemAcquire(name, holder, limit, lease)
+			if err != nil || ok {
+				return ok, err
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// SemRelease release semaphore held by `holder`, ErrSemNotHeld if not held (or reclaimed)
+func SemRelease(name string, holder string) (err error) {
+	defer observe("SemRelease", time.Now(), &err)
+	semKey := composeKey2(semModule, name)
+	n, err := redisClient.ZRem(semKey, holder).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSemNotHeld
+	}
+	return nil
+}
+
+// SemRenew renew holder lease, ErrSemNotHeld if lease already expired
+func SemRenew(name string, holder string, lease time.Duration) (err error) {
+	defer observe("SemRenew", time.Now(), &err)
+	semKey := composeKey2(semModule, name)
+
+	now := time.Now()
+	result, err := redisClient.Eval(scripts.SemRenew, []string{semKey},
+		unixMilli(now), holder, unixMilli(now.Add(lease)), lease.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return ErrSemNotHeld
+	}
+	return nil
+}
+
+// SemHolders get holders whose lease not expired
+func SemHolders(name string) (holders []string, err error) {
+	defer observe("SemHolders", time.Now(), &err)
+	semKey := composeKey2(semModule, name)
+	min := fmt.Sprintf("(%d", unixMilli(time.Now()))
+	return redisClient.ZRangeByScore(semKey, &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+}
+
+// SemCount get count of holders whose lease not expired
+func SemCount(name string) (n int64, err error) {
+	defer observe("SemCount", time.Now(), &err)
+	semKey := composeKey2(semModule, name)
+	min := fmt.Sprintf("(%d", unixMilli(time.Now()))
+	return redisClient.ZCount(semKey, min, "+inf").Result()
+}
+
+// SemDel delete semaphore, all holders released
+func SemDel(name string) {
+	var err error
+	defer observe("SemDel", time.Now(), &err)
+	semKey := composeKey2(semModule, name)
+	err = redisClient.Del(semKey).Err()
+}
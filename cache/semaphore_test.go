@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemaphore(t *testing.T) {
+	var (
+		name  = "TestSemaphore"
+		limit = int64(2)
+		lease = 100 * time.Millisecond
+	)
+	SemDel(name)
+	defer SemDel(name)
+
+	ok, err := SemAcquire(name, "h1", limit, lease)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = SemAcquire(name, "h2", limit, time.Second)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	// full
+	ok, err = SemAcquire(name, "h3", limit, time.Second)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	// holder acquire again is ok
+	ok, err = SemAcquire(name, "h2", limit, time.Second)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	n, err := SemCount(name)
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, n)
+
+	// h1 lease expired, reclaimed
+	ok, err = SemTryAcquire(name, "h3", limit, time.Second, time.Second)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	err = SemRenew(name, "h1", time.Second)
+	assert.Equal(t, ErrSemNotHeld, err)
+	err = SemRenew(name, "h3", time.Second)
+	assert.Nil(t, err)
+
+	holders, err := SemHolders(name)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"h2", "h3"}, holders)
+
+	err = SemRelease(name, "h2")
+	assert.Nil(t, err)
+	err = SemRelease(name, "h2")
+	assert.Equal(t, ErrSemNotHeld, err)
+
+	n, err = SemCount(name)
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, n)
+}
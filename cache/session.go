@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
+)
+
+// -----------------------------------------------------------------------------
+// server-side session
+// session stored in hash `s.<id>` with sliding expiration, user's session ids
+// stored in set `u.<user id>` for listing and revocation.
+// -----------------------------------------------------------------------------
+
+const (
+	sessionMetaField   = "_meta"
+	sessionValuePrefix = "v."
+)
+
+// Session a user session, values are json encoded
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	values map[string]string
+}
+
+type sessionMeta struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func sessionKey(id string) string {
+	return composeKey2(sessionModule, "s."+id)
+}
+
+func sessionUserKey(userID string) string {
+	return composeKey2(sessionModule, "u."+userID)
+}
+
+// SessionCreate create session `id` for user, expired if not accessed in `ttl`
+func SessionCreate(id string, userID string, ttl time.Duration) (s *Session, err error) {
+	defer observe("SessionCreate", time.Now(), &err)
+
+	s = &Session{ID: id, UserID: userID, CreatedAt: time.Now(), values: map[string]string{}}
+	bs, err := json.Marshal(&sessionMeta{UserID: s.UserID, CreatedAt: s.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	sKey, uKey := sessionKey(id), sessionUserKey(userID)
+	pipe := redisClient.TxPipeline()
+	pipe.Del(sKey)
+	pipe.HSet(sKey, sessionMetaField, bs)
+	pipe.PExpire(sKey, ttl)
+	pipe.SAdd(uKey, id)
+	pipe.PExpire(uKey, ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SessionGet load session and slide expiration, NotExist if expired or revoked
+func SessionGet(id string, ttl time.Duration) (s *Session, err error) {
+	defer observeGet("SessionGet", time.Now(), &err)
+
+	sKey := sessionKey(id)
+	fields, err := redisClient.HGetAll(sKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	s, err = newSession(id, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := redisClient.Pipeline()
+	pipe.PExpire(sKey, ttl)
+	pipe.PExpire(sessionUserKey(s.UserID), ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSession(id string, fields map[string]string) (*Session, error) {
+	metaStr, ok := fields[sessionMetaField]
+	if !ok {
+		return nil, NotExist
+	}
+	meta := sessionMeta{}
+	if err := json.Unmarshal([]byte(metaStr), &meta); err != nil {
+		return nil, err
+	}
+
+	s := &Session{ID: id, UserID: meta.UserID, CreatedAt: meta.CreatedAt, values: map[string]string{}}
+	for k, v := range fields {
+		if strings.HasPrefix(k, sessionValuePrefix) {
+			s.values[strings.TrimPrefix(k, sessionValuePrefix)] = v
+		}
+	}
+	return s, nil
+}
+
+// Get get session value into `value`, NotExist if not set
+func (s *Session) Get(name string, value interface{}) error {
+	v, ok := s.values[name]
+	if !ok {
+		return NotExist
+	}
+	return json.Unmarshal([]byte(v), value)
+}
+
+// GetString get string session value, "" if not set
+func (s *Session) GetString(name string) string {
+	v := ""
+	s.Get(name, &v)
+	return v
+}
+
+// Set set session value (written to cache immediately)
+func (s *Session) Set(name string, value interface{}) (err error) {
+	defer observe("SessionSet", time.Now(), &err)
+
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	result, err := redisClient.Eval(scripts.SessionSet, []string{sessionKey(s.ID)}, sessionValuePrefix+name, bs).Int64()
+	if err != nil {
+		return err
+	}
+	if result == -1 {
+		return NotExist
+	}
+	s.values[name] = string(bs)
+	return nil
+}
+
+// Del delete session value
+func (s *Session) Del(name string) (err error) {
+	defer observe("SessionDel", time.Now(), &err)
+	delete(s.values, name)
+	return redisClient.HDel(sessionKey(s.ID), sessionValuePrefix+name).Err()
+}
+
+// Names all value names
+func (s *Session) Names() []string {
+	names := make([]string, 0, len(s.values))
+	for k := range s.values {
+		names = append(names, k)
+	}
+	return names
+}
+
+// SessionRevoke delete session
+func SessionRevoke(id string) (err error) {
+	defer observe("SessionRevoke", time.Now(), &err)
+
+	sKey := sessionKey(id)
+	metaStr, err := redisClient.HGet(sKey, sessionMetaField).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+	meta := sessionMeta{}
+	if err := json.Unmarshal([]byte(metaStr), &meta); err != nil {
+		return err
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.Del(sKey)
+	pipe.SRem(sessionUserKey(meta.UserID), id)
+	_, err = pipe.Exec()
+	return err
+}
+
+// SessionUserList list user's alive sessions, expired session ids are cleaned
+func SessionUserList(userID string) (sessions []*Session, err error) {
+	defer observe("SessionUserList", time.Now(), &err)
+
+	uKey := sessionUserKey(userID)
+	ids, err := redisClient.SMembers(uKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, 0, len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipe.HGetAll(sessionKey(id)))
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(); err != nil {
+			return nil, err
+		}
+	}
+
+	sessions = make([]*Session, 0, len(ids))
+	expired := []interface{}{}
+	for i, cmd := range cmds {
+		s, err := newSession(ids[i], cmd.Val())
+		if err == NotExist {
+			expired = append(expired, ids[i])
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if len(expired) > 0 {
+		redisClient.SRem(uKey, expired...)
+	}
+	return sessions, nil
+}
+
+// SessionUserRevoke revoke all sessions of user (log out everywhere), return revoked count
+func SessionUserRevoke(userID string) (n int64, err error) {
+	defer observe("SessionUserRevoke", time.Now(), &err)
+
+	uKey := sessionUserKey(userID)
+	ids, err := redisClient.SMembers(uKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{uKey}
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	n, err = redisClient.Del(keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		n-- // user key
+	}
+	return n, nil
+}
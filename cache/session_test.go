@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	var (
+		userID = "TestSessionUser"
+		ttl    = time.Second
+	)
+	SessionUserRevoke(userID)
+	defer SessionUserRevoke(userID)
+
+	s1, err := SessionCreate("TestSession1", userID, ttl)
+	require.Nil(t, err)
+	_, err = SessionCreate("TestSession2", userID, ttl)
+	require.Nil(t, err)
+
+	type Profile struct {
+		Nickname string
+		Age      int
+	}
+	err = s1.Set("profile", &Profile{Nickname: "Bob", Age: 18})
+	require.Nil(t, err)
+	err = s1.Set("openid", "o_123")
+	require.Nil(t, err)
+
+	s, err := SessionGet("TestSession1", ttl)
+	require.Nil(t, err)
+	assert.Equal(t, userID, s.UserID)
+	assert.Equal(t, "o_123", s.GetString("openid"))
+	p := Profile{}
+	err = s.Get("profile", &p)
+	require.Nil(t, err)
+	assert.Equal(t, Profile{Nickname: "Bob", Age: 18}, p)
+	assert.Equal(t, NotExist, s.Get("whatever", &p))
+	assert.ElementsMatch(t, []string{"profile", "openid"}, s.Names())
+
+	err = s.Del("openid")
+	require.Nil(t, err)
+	s, err = SessionGet("TestSession1", ttl)
+	require.Nil(t, err)
+	assert.Equal(t, "", s.GetString("openid"))
+
+	sessions, err := SessionUserList(userID)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(sessions))
+
+	err = SessionRevoke("TestSession2")
+	require.Nil(t, err)
+	_, err = SessionGet("TestSession2", ttl)
+	assert.Equal(t, NotExist, err)
+	sessions, err = SessionUserList(userID)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(sessions))
+
+	n, err := SessionUserRevoke(userID)
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = SessionGet("TestSession1", ttl)
+	assert.Equal(t, NotExist, err)
+	assert.Equal(t, NotExist, s1.Set("openid", "o_123"))
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+// -----------------------------------------------------------------------------
+// optimistic transaction
+// WATCH key, read & decode, mutate, write in MULTI/EXEC, retry when key changed
+// by others between read and write.
+// -----------------------------------------------------------------------------
+
+var (
+	ErrTxConflict = fmt.Errorf("transaction conflict, max attempts exceeded")
+)
+
+var updateMaxAttempts int32 = 10
+
+// SetUpdateMaxAttempts max attempts of Update, default 10
+func SetUpdateMaxAttempts(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&updateMaxAttempts, int32(n))
+}
+
+// Update read-modify-write object `key` atomically (value written by SetObject).
+// `dst` pointer of object, decoded before every `fn` call; `fn` mutate `dst`, return error abort update.
+// remaining TTL is kept. NotExist if key not exist, ErrTxConflict if conflict after max attempts.
+//
+//	user := User{}
+//	err := cache.Update("user.1", &user, func() error {
+//		user.Balance += 100
+//		return nil
+//	})
+func Update(key string, dst interface{}, fn func() error) (err error) {
+	defer observe("Update", time.Now(), &err)
+
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("update dst must be non-nil pointer, got %T", dst)
+	}
+
+	realKey := composeKey(key)
+	txf := func(tx *redis.Tx) error {
+		bs, err := tx.Get(realKey).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return NotExist
+			}
+			return err
+		}
+		ttl, err := tx.PTTL(realKey).Result()
+		if err != nil {
+			return err
+		}
+		switch {
+		case ttl == -1:
+			ttl = 0 // no expiration
+		case ttl <= 0:
+			// expired after GET (-2) or expiring, SET would revive it without expiration
+			return NotExist
+		}
+
+		// reset, avoid residual of last attempt
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		if err := decodeObject(bs, dst); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		if bs, err = encodeObject(dst); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set(realKey, bs, ttl)
+			return nil
+		})
+		return err
+	}
+
+	attempts := int(atomic.LoadInt32(&updateMaxAttempts))
+	for i := 0; i < attempts; i++ {
+		err = redisClient.Watch(txf, realKey)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return ErrTxConflict
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type txAccount struct {
+	Name    string
+	Balance int64
+	Tags    []string
+}
+
+func TestUpdate(t *testing.T) {
+	var (
+		key = "TestUpdate"
+		acc = txAccount{}
+	)
+	Del(key)
+	defer Del(key)
+
+	err := Update(key, &acc, func() error { return nil })
+	assert.Equal(t, NotExist, err)
+	assert.NotNil(t, Update(key, acc, func() error { return nil }))
+
+	require.Nil(t, SetObject(key, &txAccount{Name: "foo", Balance: 1}, time.Minute))
+
+	err = Update(key, &acc, func() error {
+		acc.Balance += 10
+		acc.Tags = append(acc.Tags, "vip")
+		return nil
+	})
+	require.Nil(t, err)
+	assert.True(t, TTL(key) > 0)
+
+	// abort
+	abort := fmt.Errorf("abort")
+	err = Update(key, &acc, func() error {
+		acc.Balance = 0
+		return abort
+	})
+	assert.Equal(t, abort, err)
+
+	got := txAccount{}
+	require.Nil(t, GetObject(key, &got))
+	assert.Equal(t, txAccount{Name: "foo", Balance: 11, Tags: []string{"vip"}}, got)
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	key := "TestUpdateConcurrent"
+	require.Nil(t, SetObject(key, &txAccount{}, time.Minute))
+	defer Del(key)
+	defer SetUpdateMaxAttempts(10)
+	SetUpdateMaxAttempts(100)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			acc := txAccount{}
+			for j := 0; j < 10; j++ {
+				err := Update(key, &acc, func() error {
+					acc.Balance++
+					return nil
+				})
+				assert.Nil(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := txAccount{}
+	require.Nil(t, GetObject(key, &got))
+	assert.EqualValues(t, 100, got.Balance)
+}
+
+func TestUpdateConflict(t *testing.T) {
+	key := "TestUpdateConflict"
+	require.Nil(t, SetObject(key, &txAccount{}, time.Minute))
+	defer Del(key)
+	defer SetUpdateMaxAttempts(10)
+	SetUpdateMaxAttempts(2)
+
+	calls := 0
+	acc := txAccount{}
+	err := Update(key, &acc, func() error {
+		calls++
+		// modified by others
+		return SetObject(key, &txAccount{Balance: int64(calls)}, time.Minute)
+	})
+	assert.Equal(t, ErrTxConflict, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
+)
+
+// -----------------------------------------------------------------------------
+// verify code
+// code stored in hash `c.<key>` with verify attempts left, resend cooldown flag
+// `cd.<key>`, send count `q.<quota key>` expired at quota period end.
+// -----------------------------------------------------------------------------
+
+var (
+	ErrVCodeCooldown = fmt.Errorf("verify code resend in cooldown")
+	ErrVCodeQuota    = fmt.Errorf("verify code send quota exceeded")
+	ErrVCodeMismatch = fmt.Errorf("verify code mismatch")
+)
+
+// VCodeOptions verify code issue options
+type VCodeOptions struct {
+	TTL           time.Duration // code expire duration
+	Cooldown      time.Duration // resend cooldown, 0 for no cooldown
+	MaxAttempts   int64         // max verify attempts, code invalidated after all failed
+	Quota         int64         // max send count in quota period, 0 for unlimited
+	QuotaExpireAt time.Time     // quota period end, e.g. end of today
+}
+
+func vcodeKeys(key string, quotaKey string) []string {
+	return []string{
+		composeKey2(vcodeModule, "c."+key),
+		composeKey2(vcodeModule, "cd."+key),
+		composeKey2(vcodeModule, "q."+quotaKey),
+	}
+}
+
+// VCodeIssue store `code` for `key` (replace the old one), send count counted by `quotaKey`,
+// ErrVCodeCooldown if resend in cooldown, ErrVCodeQuota if send count reach quota.
+func VCodeIssue(key string, quotaKey string, code string, opts *VCodeOptions) (err error) {
+	defer observe("VCodeIssue", time.Now(), &err)
+
+	result, err := redisClient.Eval(scripts.VCodeIssue, vcodeKeys(key, quotaKey),
+		code, opts.TTL.Milliseconds(), opts.Cooldown.Milliseconds(), opts.MaxAttempts,
+		opts.Quota, unixMilli(opts.QuotaExpireAt)).Int64()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case -1:
+		return ErrVCodeCooldown
+	case -2:
+		return ErrVCodeQuota
+	}
+	return nil
+}
+
+// VCodeVerify check code, code consumed on success.
+// NotExist if code expired/consumed/invalidated, ErrVCodeMismatch if code not match.
+func VCodeVerify(key string, code string) (err error) {
+	defer observe("VCodeVerify", time.Now(), &err)
+
+	result, err := redisClient.Eval(scripts.VCodeVerify, vcodeKeys(key, "")[:1], code).Int64()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case -1:
+		return NotExist
+	case 0:
+		return ErrVCodeMismatch
+	}
+	return nil
+}
+
+// VCodeRevoke revoke issued code (e.g. send failure), give back the cooldown and quota
+func VCodeRevoke(key string, quotaKey string) (err error) {
+	defer observe("VCodeRevoke", time.Now(), &err)
+	return redisClient.Eval(scripts.VCodeRevoke, vcodeKeys(key, quotaKey)).Err()
+}
+
+// VCodeCooldown remaining resend cooldown, 0 for can send now
+func VCodeCooldown(key string) time.Duration {
+	var err error
+	defer observe("VCodeCooldown", time.Now(), &err)
+	d, err := redisClient.PTTL(vcodeKeys(key, "")[1]).Result()
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
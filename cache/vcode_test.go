@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVCode(t *testing.T) {
+	var (
+		key      = "login.TestVCode"
+		quotaKey = "TestVCode"
+		opts     = &VCodeOptions{
+			TTL:           time.Second,
+			Cooldown:      100 * time.Millisecond,
+			MaxAttempts:   2,
+			Quota:         2,
+			QuotaExpireAt: time.Now().Add(time.Second),
+		}
+	)
+	defer VCodeRevoke(key, quotaKey)
+	defer VCodeRevoke(key, quotaKey)
+
+	err := VCodeIssue(key, quotaKey, "1234", opts)
+	require.Nil(t, err)
+	err = VCodeIssue(key, quotaKey, "5678", opts)
+	assert.Equal(t, ErrVCodeCooldown, err)
+	assert.True(t, VCodeCooldown(key) > 0)
+
+	assert.Equal(t, ErrVCodeMismatch, VCodeVerify(key, "0000"))
+	assert.Nil(t, VCodeVerify(key, "1234"))
+	assert.Equal(t, NotExist, VCodeVerify(key, "1234")) // consumed
+
+	time.Sleep(110 * time.Millisecond)
+	assert.EqualValues(t, 0, VCodeCooldown(key))
+	err = VCodeIssue(key, quotaKey, "5678", opts)
+	require.Nil(t, err)
+
+	// invalidated after all attempts failed
+	assert.Equal(t, ErrVCodeMismatch, VCodeVerify(key, "0000"))
+	assert.Equal(t, ErrVCodeMismatch, VCodeVerify(key, "0000"))
+	assert.Equal(t, NotExist, VCodeVerify(key, "5678"))
+
+	time.Sleep(110 * time.Millisecond)
+	err = VCodeIssue(key, quotaKey, "5678", opts)
+	assert.Equal(t, ErrVCodeQuota, err)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zhangjie2012/cbl-go/cache/internal/scripts"
+)
+
+// -----------------------------------------------------------------------------
+// sliding time window counter
+// increments bucketed by time in a hash, field is bucket start unix timestamp,
+// buckets older than retention garbage collected at most once per bucket.
+// -----------------------------------------------------------------------------
+
+// WindowPoint a bucket of window counter
+type WindowPoint struct {
+	Time  time.Time `json:"time"` // bucket start time
+	Count int64     `json:"count"`
+}
+
+// WindowCounter windowed counter, e.g. bucket time.Second/time.Minute/time.Hour
+type WindowCounter struct {
+	name      string
+	bucket    time.Duration
+	retention time.Duration
+}
+
+// NewWindowCounter `bucket` min resolution (>= 1s), `retention` max window can be queried
+func NewWindowCounter(name string, bucket time.Duration, retention time.Duration) (*WindowCounter, error) {
+	if bucket < time.Second || bucket%time.Second != 0 {
+		return nil, fmt.Errorf("window bucket must be multiple of second, got %s", bucket)
+	}
+	if retention < bucket {
+		return nil, fmt.Errorf("window retention %s less than bucket %s", retention, bucket)
+	}
+	return &WindowCounter{name: name, bucket: bucket, retention: retention}, nil
+}
+
+func (w *WindowCounter) key() string {
+	return composeKey2(windowModule, w.name)
+}
+
+func (w *WindowCounter) bucketOf(t time.Time) int64 {
+	return t.Truncate(w.bucket).Unix()
+}
+
+// Incr increment n at now, return current bucket count
+func (w *WindowCounter) Incr(n int64) (int64, error) {
+	return w.IncrAt(time.Now(), n)
+}
+
+// IncrAt increment n at `t` (e.g. event time), return the bucket count.
+// `t` out of retention ignored, return 0
+func (w *WindowCounter) IncrAt(t time.Time, n int64) (v int64, err error) {
+	defer observe("WindowIncr", time.Now(), &err)
+
+	oldest := w.bucketOf(time.Now().Add(-w.retention))
+	return redisClient.Eval(scripts.WindowIncr, []string{w.key()},
+		w.bucketOf(t), n, oldest, w.retention.Milliseconds()).Int64()
+}
+
+// Series per bucket counts of trailing `window` (include current bucket), zero filled
+func (w *WindowCounter) Series(window time.Duration) (points []WindowPoint, err error) {
+	defer observe("WindowSeries", time.Now(), &err)
+
+	if window > w.retention {
+		window = w.retention
+	}
+	n := int(window / w.bucket)
+	if n < 1 {
+		n = 1
+	}
+
+	end := time.Now().Truncate(w.bucket)
+	fields := make([]string, 0, n)
+	points = make([]WindowPoint, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		t := end.Add(-time.Duration(i) * w.bucket)
+		fields = append(fields, strconv.FormatInt(t.Unix(), 10))
+		points = append(points, WindowPoint{Time: t})
+	}
+
+	values, err := redisClient.HMGet(w.key(), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if points[i].Count, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+// Sum sum of trailing `window` (include current bucket), e.g. requests in the last 5 minutes
+func (w *WindowCounter) Sum(window time.Duration) (int64, error) {
+	points, err := w.Series(window)
+	if err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, p := range points {
+		sum += p.Count
+	}
+	return sum, nil
+}
+
+// Reset delete all buckets
+func (w *WindowCounter) Reset() (err error) {
+	defer observe("WindowReset", time.Now(), &err)
+	return redisClient.Del(w.key()).Err()
+}
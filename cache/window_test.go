@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowCounter(t *testing.T) {
+	_, err := NewWindowCounter("TestWindowCounter", time.Millisecond, time.Minute)
+	assert.NotNil(t, err)
+
+	w, err := NewWindowCounter("TestWindowCounter", time.Second, 10*time.Second)
+	require.Nil(t, err)
+	w.Reset()
+	defer w.Reset()
+
+	now := time.Now()
+	v, err := w.IncrAt(now, 2)
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, v)
+	w.Incr(1)
+	w.IncrAt(now.Add(-3*time.Second), 10)
+	v, err = w.IncrAt(now.Add(-time.Minute), 100) // out of retention, ignored
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, v)
+
+	sum, err := w.Sum(2 * time.Second)
+	require.Nil(t, err)
+	assert.True(t, sum == 3 || sum == 13, sum) // bucket may roll over
+
+	sum, err = w.Sum(time.Hour)
+	require.Nil(t, err)
+	assert.EqualValues(t, 13, sum)
+
+	points, err := w.Series(5 * time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, 5, len(points))
+	assert.Equal(t, time.Now().Truncate(time.Second).Unix(), points[4].Time.Unix())
+
+	fields, err := C().HKeys(w.key()).Result()
+	require.Nil(t, err)
+	assert.Contains(t, fields, strconv.FormatInt(now.Truncate(time.Second).Unix(), 10))
+	assert.NotContains(t, fields, strconv.FormatInt(now.Add(-time.Minute).Truncate(time.Second).Unix(), 10))
+}
@@ -0,0 +1,101 @@
+/*
+cblcache inspect keys under cache app namespace.
+
+Usage:
+
+	cblcache [flags] list|del|inspect [pattern]
+
+e.g. wipe all `yangyin.*` plain keys of app "cbl":
+
+	cblcache -app cbl del 'yangyin.*'
+
+list all message queue keys:
+
+	cblcache -app cbl -module mq list
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+var (
+	app      = flag.String("app", "", "app name, keys prefix `app:`")
+	addr     = flag.String("addr", "localhost:6379", "redis address")
+	password = flag.String("password", "", "redis password")
+	db       = flag.Int("db", 0, "redis db")
+	module   = flag.String("module", "plain", "key module: plain/mq/counter/set/dislock/sem/cron/idem/session/vcode/window/geo/revoke/rbac")
+	batch    = flag.Int("batch", 100, "del UNLINK batch size")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] list|del|inspect [pattern]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// fail print error, return exit code
+func fail(format string, a ...interface{}) int {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	return 1
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	os.Exit(run())
+}
+
+// run return exit code, exit after deferred calls (e.g. CloseCache) done
+func run() int {
+	if *app == "" || flag.NArg() < 1 {
+		usage()
+		return 2
+	}
+	m, ok := cache.ParseModule(*module)
+	if !ok {
+		return fail("unknown module: %s", *module)
+	}
+	pattern := "*"
+	if flag.NArg() > 1 {
+		pattern = flag.Arg(1)
+	}
+
+	if err := cache.InitCache(*app, *addr, *password, *db); err != nil {
+		return fail("redis init failure, err=%s", err)
+	}
+	defer cache.CloseCache()
+
+	var err error
+	switch flag.Arg(0) {
+	case "list":
+		err = cache.Scan(m, pattern, func(key string) error {
+			fmt.Println(key)
+			return nil
+		})
+	case "del":
+		var n int64
+		n, err = cache.Purge(m, pattern, *batch)
+		fmt.Printf("%d keys deleted\n", n)
+	case "inspect":
+		err = cache.Scan(m, pattern, func(key string) error {
+			info, err := cache.Inspect(m, key)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\t%s\t%s\n", info.Key, info.Type, info.TTL)
+			return nil
+		})
+	default:
+		usage()
+		return 2
+	}
+
+	if err != nil {
+		return fail("%s failure, err=%s", flag.Arg(0), err)
+	}
+	return 0
+}
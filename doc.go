@@ -1,12 +1,21 @@
 /*
 Package cbl is a common basic library for go.
 
+  - `accesslog` 访问日志中间件（json/logfmt）
   - `conv` 类型转换
-  - `errcode` web 常用错误码
+  - `errcode` web 常用错误码, 业务错误 `Error`（业务码 + http status）
   - `gen` token/id 生成器
+  - `validate` 参数校验错误翻译
+  - `i18n` 错误码注册, 多语言错误信息（`Accept-Language`）
   - `ginext` gin 扩展（标准化）
+  - `handler` gin handler 适配器, 绑定、校验、统一响应
+  - `page` 分页（page/size, cursor）请求解析和响应
+  - `jwt` JWT 签发/校验（HS256/RS256/ES256）, 认证中间件, 吊销列表
   - `net` 网络扩展
   - `prom` prometheus middware
+  - `rbac` 角色权限控制中间件
+  - `recovery` panic 恢复中间件, 统一响应
+  - `trace` request id, W3C traceparent 中间件和 http 透传
   - `strings` 字符串扩展
   - `wechat_mini` 微信小程序
   - `yinyang` 公历和农历转换
@@ -1,8 +1,13 @@
 package cbl
 
+import (
+	"net/http"
+)
+
 var (
 	ErrNone                = ""                      // no error 没错
 	ErrBadRequest          = "Bad Request"           // bad request 错误的请求（参数错误）
+	ErrDuplicateRequest    = "Duplicate Request"     // duplicate request in progress 重复请求, 正在处理中
 	ErrInternalServerError = "Internal Server Error" // server logic error 服务器内部错误, 服务器自身逻辑问题
 	ErrInvalidParams       = "Invalid Params"        // invalid params 参数格式错误
 	ErrInvalidPassword     = "Invalid Password"      // invalid password 密码错误
@@ -13,3 +18,33 @@ var (
 	ErrPermissionDenied    = "Permission Denied"     // permission denied 权限不足
 	ErrTooManyRequests     = "Too Many Requests"     // too many requests 请求过于频繁, 限流
 )
+
+// business codes, `Resp.Code` of predefined errors. 0 ok, 1 unclassified error (message only)
+const (
+	CodeBadRequest          = 40000
+	CodeInvalidParams       = 40001
+	CodeInvalidPassword     = 40002
+	CodeInvalidVerifyCode   = 40003
+	CodeOutOfRange          = 40004
+	CodeLoginRequired       = 40100
+	CodePermissionDenied    = 40300
+	CodeNotFound            = 40400
+	CodeDuplicateRequest    = 40900
+	CodeTooManyRequests     = 42900
+	CodeInternalServerError = 50000
+)
+
+// predefined errors of Err* messages
+var (
+	ErrorBadRequest          = NewError(CodeBadRequest, http.StatusBadRequest, ErrBadRequest)
+	ErrorDuplicateRequest    = NewError(CodeDuplicateRequest, http.StatusConflict, ErrDuplicateRequest)
+	ErrorInternalServerError = NewError(CodeInternalServerError, http.StatusInternalServerError, ErrInternalServerError)
+	ErrorInvalidParams       = NewError(CodeInvalidParams, http.StatusBadRequest, ErrInvalidParams)
+	ErrorInvalidPassword     = NewError(CodeInvalidPassword, http.StatusBadRequest, ErrInvalidPassword)
+	ErrorInvalidVerifyCode   = NewError(CodeInvalidVerifyCode, http.StatusBadRequest, ErrInvalidVerifyCode)
+	ErrorLoginRequired       = NewError(CodeLoginRequired, http.StatusUnauthorized, ErrLoginRequired)
+	ErrorNotFound            = NewError(CodeNotFound, http.StatusNotFound, ErrNotFound)
+	ErrorOutOfRange          = NewError(CodeOutOfRange, http.StatusBadRequest, ErrOutOfRange)
+	ErrorPermissionDenied    = NewError(CodePermissionDenied, http.StatusForbidden, ErrPermissionDenied)
+	ErrorTooManyRequests     = NewError(CodeTooManyRequests, http.StatusTooManyRequests, ErrTooManyRequests)
+)
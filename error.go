@@ -0,0 +1,116 @@
+package cbl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error business error, carry numeric code for client and http status for response.
+// the predefined instances (errcode.go) are shared, use Wrap/WithDetails/WithMessage
+// to derive a new one, errors.Is matched by code:
+//
+//	if err := db.Find(&order).Error; err != nil {
+//		ErrorResponse(c, ErrorNotFound.Wrap(err))
+//		return
+//	}
+//	errors.Is(err, ErrorNotFound) // true
+type Error struct {
+	Code    int                    // business code, Resp.Code
+	Message string                 // client readable message, Resp.Error, may contain `{name}` placeholders
+	Status  int                    // http status, 200 if zero
+	Details interface{}            // optional details, Resp.Data
+	Params  map[string]interface{} // message placeholders values
+	cause   error
+	custom  bool // message replaced by WithMessage, not localized
+}
+
+// NewError create business error
+func NewError(code int, status int, message string) *Error {
+	return &Error{Code: code, Message: message, Status: status}
+}
+
+// Error message with cause, cause not exposed to client by ErrorResponse
+func (e *Error) Error() string {
+	msg := renderMessage(e.Message, e.Params)
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s", msg, e.cause)
+	}
+	return msg
+}
+
+// Unwrap wrapped cause
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is same business code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap copy with cause
+func (e *Error) Wrap(cause error) *Error {
+	n := *e
+	n.cause = cause
+	return &n
+}
+
+// WithDetails copy with details
+func (e *Error) WithDetails(details interface{}) *Error {
+	n := *e
+	n.Details = details
+	return &n
+}
+
+// WithMessage copy with message replaced, e.g. more specific one
+func (e *Error) WithMessage(format string, a ...interface{}) *Error {
+	n := *e
+	if len(a) > 0 {
+		n.Message = fmt.Sprintf(format, a...)
+	} else {
+		n.Message = format
+	}
+	n.custom = true
+	return &n
+}
+
+// WithParams copy with message placeholders values
+//
+//	ErrorOutOfRange.WithParams(map[string]interface{}{"max": 100})
+func (e *Error) WithParams(params map[string]interface{}) *Error {
+	n := *e
+	n.Params = params
+	return &n
+}
+
+// HTTPStatus response status, 200 if not set
+func (e *Error) HTTPStatus() int {
+	if e.Status == 0 {
+		return http.StatusOK
+	}
+	return e.Status
+}
+
+// AsError find *Error in err chain, typed nil *Error not found
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) && e != nil {
+		return e, true
+	}
+	return nil, false
+}
+
+// renderMessage replace `{name}` in message with params
+func renderMessage(message string, params map[string]interface{}) string {
+	if len(params) == 0 {
+		return message
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(message)
+}
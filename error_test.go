@@ -0,0 +1,80 @@
+package cbl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("record not found")
+	err := ErrorNotFound.Wrap(cause)
+	assert.Equal(t, "Not Found: record not found", err.Error())
+	assert.True(t, errors.Is(err, ErrorNotFound))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrorBadRequest))
+	assert.Equal(t, "Not Found", ErrorNotFound.Error()) // predefined not modified
+
+	wrapped := fmt.Errorf("load order: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrorNotFound))
+	e, ok := AsError(wrapped)
+	assert.True(t, ok)
+	assert.Equal(t, CodeNotFound, e.Code)
+	assert.Equal(t, http.StatusNotFound, e.HTTPStatus())
+
+	_, ok = AsError(cause)
+	assert.False(t, ok)
+
+	e = ErrorOutOfRange.WithMessage("page %d out of range", 9).WithDetails(map[string]int{"max": 5})
+	assert.Equal(t, "page 9 out of range", e.Message)
+	assert.Equal(t, ErrOutOfRange, ErrorOutOfRange.Message)
+	assert.True(t, errors.Is(e, ErrorOutOfRange))
+	assert.Equal(t, http.StatusOK, NewError(1001, 0, "custom").HTTPStatus())
+}
+
+func TestErrorResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/string", func(c *gin.Context) {
+		ErrorResponse(c, ErrNotFound)
+	})
+	router.GET("/error", func(c *gin.Context) {
+		ErrorResponse(c, fmt.Errorf("query: %w", ErrorNotFound.Wrap(errors.New("sql: no rows"))))
+	})
+	router.GET("/nil", func(c *gin.Context) {
+		var e *Error
+		var err error = e // typed nil
+		ErrorResponse(c, err)
+	})
+	router.GET("/details", func(c *gin.Context) {
+		ErrorResponse(c, ErrorInvalidParams.WithDetails(map[string]string{"name": "required"}))
+	})
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/string")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"code":1,"data":null,"error":"Not Found"}`, w.Body.String())
+
+	w = do("/error")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"code":40400,"data":null,"error":"Not Found"}`, w.Body.String())
+
+	w = do("/nil")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"code":1,"data":null,"error":"server error"}`, w.Body.String())
+
+	w = do("/details")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"code":40001,"data":{"name":"required"},"error":"Invalid Params"}`, w.Body.String())
+}
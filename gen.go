@@ -10,7 +10,9 @@ specific string generated. about UUID:
 package cbl
 
 import (
+	crand "crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"time"
@@ -49,6 +51,30 @@ func GenRSessionID() string {
 	return GenUUIDV4()
 }
 
+// GenTraceID W3C trace context trace id, 16 random bytes hex (never all zero)
+func GenTraceID() string {
+	return genNonZeroHex(16)
+}
+
+// GenSpanID W3C trace context span (parent) id, 8 random bytes hex (never all zero)
+func GenSpanID() string {
+	return genNonZeroHex(8)
+}
+
+func genNonZeroHex(n int) string {
+	b := make([]byte, n)
+	for {
+		if _, err := crand.Read(b); err != nil {
+			rand.Read(b)
+		}
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
 func genVerifyCode(pool string, width int) string {
 	s := rand.NewSource(time.Now().UnixNano())
 	r := rand.New(s)
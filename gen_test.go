@@ -2,6 +2,8 @@ package cbl
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGenGUID(t *testing.T) {
@@ -21,3 +23,9 @@ func TestGenVerifyCode(t *testing.T) {
 	t.Log(GenVerifyCodeAny("ABCDEFGHIJKLMNOPQRSTUVWXYZ", 8))
 	t.Log(GenVerifyCodeAny("!@#$%^&*()", 8))
 }
+
+func TestGenTraceID(t *testing.T) {
+	assert.Len(t, GenTraceID(), 32)
+	assert.Len(t, GenSpanID(), 16)
+	assert.NotEqual(t, GenTraceID(), GenTraceID())
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type Resp struct {
-	Code  int         `json:"code"`
-	Data  interface{} `json:"data"`
-	Error string      `json:"error"`
+	Code      int         `json:"code"`
+	Data      interface{} `json:"data"`
+	Error     string      `json:"error"`
+	RequestID string      `json:"request_id,omitempty"` // see RequestIDOptions.InBody
 }
 
 var (
@@ -19,17 +20,35 @@ var (
 
 func SuccessResponse(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, &Resp{
-		Code:  codeOK,
-		Data:  data,
-		Error: ErrNone,
+		Code:      codeOK,
+		Data:      data,
+		Error:     ErrNone,
+		RequestID: responseRequestID(c),
 	})
 }
 
+// ErrorResponse error response, `err` is message string or error.
+// *Error (or error wraps it) responses its code, status, localized message (see Locale)
+// and details (as data), others (include nil *Error) response code 1 with http 200.
 func ErrorResponse(c *gin.Context, err interface{}) {
+	if t, ok := err.(error); ok {
+		if be, ok := AsError(t); ok {
+			c.JSON(be.HTTPStatus(), &Resp{
+				Code:      be.Code,
+				Data:      be.Details,
+				Error:     LocalizedMessage(c, be),
+				RequestID: responseRequestID(c),
+			})
+			return
+		}
+	}
+
 	e := ""
 	switch t := err.(type) {
 	case string:
 		e = t
+	case *Error: // typed nil, others responded above
+		e = "server error"
 	case error:
 		e = t.Error()
 	default:
@@ -37,8 +56,9 @@ func ErrorResponse(c *gin.Context, err interface{}) {
 	}
 
 	c.JSON(http.StatusOK, &Resp{
-		Code:  codeError,
-		Data:  nil,
-		Error: e,
+		Code:      codeError,
+		Data:      nil,
+		Error:     e,
+		RequestID: responseRequestID(c),
 	})
 }
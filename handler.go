@@ -0,0 +1,189 @@
+package cbl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	ginContextType = reflect.TypeOf((*gin.Context)(nil))
+	contextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Handle wrap service function into gin handler, `fn` is
+//
+//	func(ctx context.Context, req *Req) (*Resp, error) // ctx is request context
+//	func(c *gin.Context, req *Req) (*Resp, error)
+//
+// Req fields bound by tags: `json` json body, `form` query (and form body), `header` headers,
+// `uri` path params, in this order, then validated by `binding` tag once. every source only
+// set fields tagged for it (json body also untagged ones), can't smuggle fields of others.
+// binding error response by BindErrorResponse, returned error by ErrorResponse (not *Error
+// ones as ErrorInternalServerError, recorded in c.Errors), success by SuccessResponse.
+// panic if `fn` signature invalid.
+//
+//	router.POST("/orders/:shop", cbl.Handle(func(ctx context.Context, req *CreateOrderReq) (*Order, error) {
+//		return svc.CreateOrder(ctx, req)
+//	}))
+func Handle(fn interface{}) gin.HandlerFunc {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 2 || ft.NumOut() != 2 ||
+		(ft.In(0) != ginContextType && ft.In(0) != contextType) ||
+		ft.In(1).Kind() != reflect.Ptr || ft.In(1).Elem().Kind() != reflect.Struct ||
+		ft.Out(1) != errorType {
+		panic(fmt.Sprintf("cbl.Handle: want func(context.Context|*gin.Context, *Req) (Resp, error), got %s", ft))
+	}
+	reqType := ft.In(1).Elem()
+	sources := requestSources(reqType)
+	withGinContext := ft.In(0) == ginContextType
+
+	return func(c *gin.Context) {
+		req := reflect.New(reqType)
+		if err := bindRequest(c, req.Interface(), sources); err != nil {
+			BindErrorResponse(c, err)
+			return
+		}
+
+		var ctx reflect.Value
+		if withGinContext {
+			ctx = reflect.ValueOf(c)
+		} else {
+			ctx = reflect.ValueOf(c.Request.Context())
+		}
+		out := fv.Call([]reflect.Value{ctx, req})
+
+		if c.Writer.Written() {
+			return // fn responded itself, e.g. file download
+		}
+		if err, _ := out[1].Interface().(error); err != nil {
+			c.Error(err)
+			if _, ok := AsError(err); !ok {
+				err = ErrorInternalServerError.Wrap(err)
+			}
+			ErrorResponse(c, err)
+			return
+		}
+		SuccessResponse(c, out[0].Interface())
+	}
+}
+
+// request binding sources, by tags of request struct
+type bindSources struct {
+	uri, form, header, json bool
+}
+
+func requestSources(t reflect.Type) bindSources {
+	var s bindSources
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			e := requestSources(f.Type)
+			s.uri, s.form, s.header, s.json = s.uri || e.uri, s.form || e.form, s.header || e.header, s.json || e.json
+			continue
+		}
+		_, uri := f.Tag.Lookup("uri")
+		_, form := f.Tag.Lookup("form")
+		_, header := f.Tag.Lookup("header")
+		_, json := f.Tag.Lookup("json")
+		s.uri, s.form, s.header, s.json = s.uri || uri, s.form || form, s.header || header, s.json || json
+	}
+	return s
+}
+
+// bindRequest bind all sources, validate once. gin bindings validate every time, partial
+// bound request validation errors ignored until all bound.
+func bindRequest(c *gin.Context, req interface{}, s bindSources) error {
+	bind := func(err error) error {
+		var ves validator.ValidationErrors
+		if err != nil && !errors.As(err, &ves) {
+			return err
+		}
+		return nil
+	}
+
+	if hasBody(c.Request) && (s.json || s.form) {
+		b := binding.Default(c.Request.Method, c.ContentType())
+		tag := "json"
+		if b == binding.Form || b == binding.FormPost || b == binding.FormMultipart {
+			tag = "form"
+		}
+		if err := bind(bindSource(req, tag, func(obj interface{}) error { return c.ShouldBindWith(obj, b) })); err != nil {
+			return err
+		}
+	}
+	if s.form {
+		if err := bind(bindSource(req, "form", c.ShouldBindQuery)); err != nil {
+			return err
+		}
+	}
+	if s.header {
+		if err := bind(bindSource(req, "header", c.ShouldBindHeader)); err != nil {
+			return err
+		}
+	}
+	if s.uri {
+		if err := bind(bindSource(req, "uri", c.ShouldBindUri)); err != nil {
+			return err
+		}
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// bindSource bind a source into a scratch value, copy fields of the source (see isSourceField)
+// to req. gin bindings fall back to go field name for untagged fields and encoding/json match
+// names case-insensitively, bound in place a source could set fields of others.
+func bindSource(req interface{}, tag string, bind func(obj interface{}) error) error {
+	dst := reflect.ValueOf(req).Elem()
+	src := reflect.New(dst.Type())
+	err := bind(src.Interface())
+	copySourceFields(dst, src.Elem(), tag)
+	return err
+}
+
+// copySourceFields zero values not copied, field absent in the source not cleared
+func copySourceFields(dst reflect.Value, src reflect.Value, tag string) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get(tag) == "" {
+			copySourceFields(dst.Field(i), src.Field(i), tag)
+			continue
+		}
+		if f.PkgPath != "" || !isSourceField(f, tag) || src.Field(i).IsZero() {
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+	}
+}
+
+// isSourceField field tagged by the source, json body also fill fields not tagged by any source
+func isSourceField(f reflect.StructField, tag string) bool {
+	if name, ok := f.Tag.Lookup(tag); ok {
+		return name != "-"
+	}
+	if tag != "json" {
+		return false
+	}
+	for _, key := range []string{"uri", "form", "header"} {
+		if _, ok := f.Tag.Lookup(key); ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasBody(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
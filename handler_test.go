@@ -0,0 +1,157 @@
+package cbl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandle(t *testing.T) {
+	UseJSONFieldNames()
+	type Paging struct {
+		Page int `form:"page" binding:"min=1"`
+	}
+	type UpdateOrderReq struct {
+		Paging
+		Shop    string `uri:"shop" binding:"required"`
+		TraceID string `header:"X-Trace-Id"`
+		Name    string `json:"name" binding:"required"`
+		Count   int    `json:"count"`
+	}
+	type UpdateOrderResp struct {
+		Shop    string `json:"shop"`
+		Page    int    `json:"page"`
+		TraceID string `json:"trace_id"`
+		Name    string `json:"name"`
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/shops/:shop/orders", Handle(func(ctx context.Context, req *UpdateOrderReq) (*UpdateOrderResp, error) {
+		switch req.Name {
+		case "missing":
+			return nil, ErrorNotFound.Wrap(errors.New("no rows"))
+		case "broken":
+			return nil, errors.New("db connection refused")
+		}
+		return &UpdateOrderResp{Shop: req.Shop, Page: req.Page, TraceID: req.TraceID, Name: req.Name}, nil
+	}))
+	router.GET("/shops/:shop", Handle(func(c *gin.Context, req *struct {
+		Shop string `uri:"shop"`
+	}) (string, error) {
+		return c.Param("shop") + "/" + req.Shop, nil
+	}))
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("X-Trace-Id", "t1")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/shops/s1/orders?page=2", `{"name":"book"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"code":0,"data":{"shop":"s1","page":2,"trace_id":"t1","name":"book"},"error":""}`, w.Body.String())
+
+	w = do(http.MethodPost, "/shops/s1/orders?page=0", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"code":40001,"error":"Invalid Params","data":{"page":"page must be 1 or greater","name":"name is required"}}`, w.Body.String())
+
+	w = do(http.MethodPost, "/shops/s1/orders?page=1", `{"name":"book","count":"x"}`)
+	assert.JSONEq(t, `{"code":40001,"error":"Invalid Params","data":{"count":"count must be int"}}`, w.Body.String())
+
+	w = do(http.MethodPost, "/shops/s1/orders?page=1", `{"name":"missing"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"code":40400,"data":null,"error":"Not Found"}`, w.Body.String())
+
+	w = do(http.MethodPost, "/shops/s1/orders?page=1", `{"name":"broken"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, `{"code":50000,"data":null,"error":"Internal Server Error"}`, w.Body.String())
+
+	w = do(http.MethodGet, "/shops/s2", "")
+	assert.Equal(t, `{"code":0,"data":"s2/s2","error":""}`, w.Body.String())
+}
+
+func TestHandleBodyNotOverride(t *testing.T) {
+	type Req struct {
+		UserID string `header:"X-User-Id" binding:"required"`
+		Page   int    `form:"page"`
+		Name   string `json:"name"`
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", Handle(func(ctx context.Context, req *Req) (*Req, error) {
+		return req, nil
+	}))
+
+	do := func(path string, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"userid":"victim","page":99,"name":"book"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set("X-User-Id", userID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/orders?page=2", "me")
+	assert.Equal(t, `{"code":0,"data":{"UserID":"me","Page":2,"name":"book"},"error":""}`, w.Body.String())
+
+	// not set by body even if header/query absent
+	w = do("/orders", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("/orders", "me")
+	assert.Equal(t, `{"code":0,"data":{"UserID":"me","Page":0,"name":"book"},"error":""}`, w.Body.String())
+}
+
+func TestHandleSourceNotSmuggled(t *testing.T) {
+	type Req struct {
+		UserID string `header:"X-User-Id" binding:"required"`
+		Page   int    `form:"page"`
+		Shop   string `uri:"shop"`
+		Name   string `json:"name"`
+		Note   string
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/shops/:shop/orders", Handle(func(ctx context.Context, req *Req) (*Req, error) {
+		return req, nil
+	}))
+
+	do := func(path string, header http.Header, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// query -> header field (go field name fallback of query binding)
+	w := do("/shops/s1/orders?UserID=victim&Shop=other&Note=q", http.Header{}, `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// header -> body field, header -> query field
+	w = do("/shops/s1/orders", http.Header{"X-User-Id": {"me"}, "Name": {"fromheader"}, "Page": {"7"}, "Note": {"h"}},
+		`{"name":"book","note":"body"}`)
+	assert.Equal(t, `{"code":0,"data":{"UserID":"me","Page":0,"Shop":"s1","name":"book","Note":"body"},"error":""}`, w.Body.String())
+}
+
+func TestHandleInvalid(t *testing.T) {
+	assert.Panics(t, func() { Handle(func(c *gin.Context) {}) })
+	assert.Panics(t, func() { Handle(func(ctx context.Context, req string) (string, error) { return "", nil }) })
+	assert.Panics(t, func() { Handle(func(ctx context.Context, req *struct{}) (string, string) { return "", "" }) })
+}
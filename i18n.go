@@ -0,0 +1,215 @@
+package cbl
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -----------------------------------------------------------------------------
+// error code registry, localized messages of business codes.
+// message templates per locale, `{name}` placeholders rendered by Error.Params.
+//
+//	var ErrorOrderPaid = cbl.RegisterError(10001, http.StatusConflict, cbl.Messages{
+//		cbl.LocaleEn: "order {id} already paid",
+//		cbl.LocaleZh: "订单 {id} 已支付",
+//	})
+//
+//	cbl.ErrorResponse(c, ErrorOrderPaid.WithParams(map[string]interface{}{"id": id}))
+//
+// ErrorResponse resolve locale from `Accept-Language` (or SetLocale), fallback to default locale.
+// -----------------------------------------------------------------------------
+
+const (
+	LocaleEn = "en"
+	LocaleZh = "zh"
+)
+
+// Messages locale -> message template
+type Messages map[string]string
+
+type errorRegistry struct {
+	sync.RWMutex
+	defaultLocale string
+	messages      map[int]Messages
+	validations   map[string]Messages // validation tag -> field message templates, see validate.go
+	locales       map[string]struct{} // all registered locales
+}
+
+var registry = &errorRegistry{
+	defaultLocale: LocaleEn,
+	messages:      map[int]Messages{},
+	validations:   map[string]Messages{},
+	locales:       map[string]struct{}{},
+}
+
+const localeContextKey = "_cbl_locale_"
+
+func init() {
+	builtin := map[int]Messages{
+		CodeBadRequest:          {LocaleEn: ErrBadRequest, LocaleZh: "错误的请求"},
+		CodeDuplicateRequest:    {LocaleEn: ErrDuplicateRequest, LocaleZh: "重复请求, 正在处理中"},
+		CodeInternalServerError: {LocaleEn: ErrInternalServerError, LocaleZh: "服务器内部错误"},
+		CodeInvalidParams:       {LocaleEn: ErrInvalidParams, LocaleZh: "参数格式错误"},
+		CodeInvalidPassword:     {LocaleEn: ErrInvalidPassword, LocaleZh: "密码错误"},
+		CodeInvalidVerifyCode:   {LocaleEn: ErrInvalidVerifyCode, LocaleZh: "验证码无效"},
+		CodeLoginRequired:       {LocaleEn: ErrLoginRequired, LocaleZh: "需要登录"},
+		CodeNotFound:            {LocaleEn: ErrNotFound, LocaleZh: "资源不存在"},
+		CodeOutOfRange:          {LocaleEn: ErrOutOfRange, LocaleZh: "越界访问"},
+		CodePermissionDenied:    {LocaleEn: ErrPermissionDenied, LocaleZh: "权限不足"},
+		CodeTooManyRequests:     {LocaleEn: ErrTooManyRequests, LocaleZh: "请求过于频繁"},
+	}
+	for code, messages := range builtin {
+		RegisterMessages(code, messages)
+	}
+}
+
+// SetDefaultLocale locale used when request locale not registered, `en` by default
+func SetDefaultLocale(locale string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.defaultLocale = normalizeLocale(locale)
+}
+
+// RegisterMessages register (merge) message templates of code, replace existing locale
+func RegisterMessages(code int, messages Messages) {
+	registry.Lock()
+	defer registry.Unlock()
+	m, ok := registry.messages[code]
+	if !ok {
+		m = Messages{}
+		registry.messages[code] = m
+	}
+	registry.merge(m, messages)
+}
+
+// merge messages into dst, must hold lock
+func (r *errorRegistry) merge(dst Messages, messages Messages) {
+	for locale, tmpl := range messages {
+		locale = normalizeLocale(locale)
+		dst[locale] = tmpl
+		r.locales[locale] = struct{}{}
+	}
+}
+
+// RegisterError register messages and create error, message of default locale as Error.Message
+func RegisterError(code int, status int, messages Messages) *Error {
+	RegisterMessages(code, messages)
+	msg, _ := LookupMessage(code, "", nil)
+	return NewError(code, status, msg)
+}
+
+// LookupMessage rendered message of code in locale, fallback to default locale,
+// false if code not registered
+func LookupMessage(code int, locale string, params map[string]interface{}) (string, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	m, ok := registry.messages[code]
+	if !ok {
+		return "", false
+	}
+	tmpl, ok := m.pick(locale)
+	if !ok {
+		return "", false
+	}
+	return renderMessage(tmpl, params), true
+}
+
+// pick template of locale, fallback to language (zh-cn -> zh) and default locale. must hold registry lock
+func (m Messages) pick(locale string) (string, bool) {
+	locale = normalizeLocale(locale)
+	if tmpl, ok := m[locale]; ok {
+		return tmpl, true
+	}
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		if tmpl, ok := m[locale[:i]]; ok {
+			return tmpl, true
+		}
+	}
+	tmpl, ok := m[registry.defaultLocale]
+	return tmpl, ok
+}
+
+// SetLocale set locale of the request, e.g. from user settings, take precedence over `Accept-Language`
+func SetLocale(c *gin.Context, locale string) {
+	c.Set(localeContextKey, normalizeLocale(locale))
+}
+
+// Locale locale of the request: SetLocale, best registered match of `Accept-Language`, default locale
+func Locale(c *gin.Context) string {
+	if v, ok := c.Get(localeContextKey); ok {
+		if locale, ok := v.(string); ok && locale != "" {
+			return locale
+		}
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, tag := range parseAcceptLanguage(c.GetHeader("Accept-Language")) {
+		if _, ok := registry.locales[tag]; ok {
+			return tag
+		}
+		// zh-cn -> zh
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			if _, ok := registry.locales[tag[:i]]; ok {
+				return tag[:i]
+			}
+		}
+	}
+	return registry.defaultLocale
+}
+
+// LocalizedMessage message of e in request locale, e.Message if not registered or replaced by WithMessage
+func LocalizedMessage(c *gin.Context, e *Error) string {
+	if !e.custom {
+		if msg, ok := LookupMessage(e.Code, Locale(c), e.Params); ok {
+			return msg
+		}
+	}
+	return renderMessage(e.Message, e.Params)
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// parseAcceptLanguage language tags ordered by quality, e.g. "zh-CN,zh;q=0.9,en;q=0.8"
+func parseAcceptLanguage(header string) []string {
+	type tagQ struct {
+		tag string
+		q   float64
+	}
+	tags := []tagQ{}
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		q := 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			param := strings.TrimSpace(part[i+1:])
+			part = strings.TrimSpace(part[:i])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if part == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, tagQ{normalizeLocale(part), q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, t.tag)
+	}
+	return result
+}
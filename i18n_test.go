@@ -0,0 +1,67 @@
+package cbl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"zh-cn", "zh", "en"}, parseAcceptLanguage("zh-CN,zh;q=0.9,en;q=0.8"))
+	assert.Equal(t, []string{"en-us", "zh"}, parseAcceptLanguage("zh;q=0.5, en-US, *;q=0.1, fr;q=0"))
+	assert.Equal(t, []string{}, parseAcceptLanguage(""))
+}
+
+func TestLookupMessage(t *testing.T) {
+	const code = 99001
+	e := RegisterError(code, http.StatusConflict, Messages{
+		LocaleEn: "order {id} already paid",
+		LocaleZh: "订单 {id} 已支付",
+	})
+	assert.Equal(t, "order {id} already paid", e.Message)
+	assert.Equal(t, "order 42 already paid", e.WithParams(map[string]interface{}{"id": 42}).Error())
+
+	msg, ok := LookupMessage(code, "zh", map[string]interface{}{"id": 42})
+	assert.True(t, ok)
+	assert.Equal(t, "订单 42 已支付", msg)
+	msg, ok = LookupMessage(code, "fr", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "order {id} already paid", msg)
+	_, ok = LookupMessage(99999, "en", nil)
+	assert.False(t, ok)
+}
+
+func TestLocalizedErrorResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/notfound", func(c *gin.Context) {
+		ErrorResponse(c, ErrorNotFound)
+	})
+	router.GET("/custom", func(c *gin.Context) {
+		ErrorResponse(c, ErrorNotFound.WithMessage("order not found"))
+	})
+	router.GET("/settings", func(c *gin.Context) {
+		SetLocale(c, "zh_CN")
+		ErrorResponse(c, ErrorLoginRequired)
+	})
+
+	do := func(path string, lang string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, `{"code":40400,"data":null,"error":"Not Found"}`, do("/notfound", ""))
+	assert.Equal(t, `{"code":40400,"data":null,"error":"资源不存在"}`, do("/notfound", "zh-CN,zh;q=0.9,en;q=0.8"))
+	assert.Equal(t, `{"code":40400,"data":null,"error":"Not Found"}`, do("/notfound", "fr-FR,en-US;q=0.8"))
+	assert.Equal(t, `{"code":40400,"data":null,"error":"order not found"}`, do("/custom", "zh"))
+	// zh-cn not registered, use zh
+	assert.Equal(t, `{"code":40100,"data":null,"error":"需要登录"}`, do("/settings", "en"))
+}
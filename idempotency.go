@@ -0,0 +1,100 @@
+package cbl
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// bodyWriter copy response body
+type bodyWriter struct {
+	gin.ResponseWriter
+	body  *bytes.Buffer
+	limit int // max bytes copied, 0 for all
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.copy(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.copy([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) copy(b []byte) {
+	if w.limit > 0 {
+		if n := w.limit - w.body.Len(); n < len(b) {
+			b = b[:n]
+		}
+	}
+	w.body.Write(b)
+}
+
+// IdempotencyMiddleware guard request with header `Idempotency-Key`, key scope is method + route + user
+// (session user or jwt subject, so put it after SessionManager/JWTManager middleware), users never share
+// responses by the same key.
+//   - first request processed, response stored `expire` duration
+//   - duplicate requests replay the stored response (with header `Idempotent-Replayed: true`)
+//   - concurrent duplicate requests get ErrorDuplicateRequest (409)
+//
+// `timeout` max processing duration. 5xx response or panic not stored, client can retry with the same key.
+// request is not blocked if cache unavailable.
+func IdempotencyMiddleware(timeout time.Duration, expire time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		key = fmt.Sprintf("%s.%s.%s.%s", c.Request.Method, c.FullPath(), requestUserID(c), key)
+
+		resp, err := cache.IdemReserve(key, timeout)
+		if err == cache.ErrIdemInProgress {
+			ErrorResponse(c, ErrorDuplicateRequest)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.Next()
+			return
+		}
+		if resp != nil {
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Data(resp.Status, resp.ContentType, resp.Body)
+			c.Abort()
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				cache.IdemRelease(key)
+				panic(r)
+			}
+		}()
+
+		w := &bodyWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = w
+		c.Next()
+
+		if w.Status() >= http.StatusInternalServerError {
+			cache.IdemRelease(key)
+			return
+		}
+		cache.IdemComplete(key, &cache.IdemResponse{
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		}, expire)
+	}
+}
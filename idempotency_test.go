@@ -0,0 +1,92 @@
+package cbl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var count int64
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", IdempotencyMiddleware(time.Second, time.Second), func(c *gin.Context) {
+		n := atomic.AddInt64(&count, 1)
+		SuccessResponse(c, n)
+	})
+
+	key := GenUUIDV4()
+	defer cache.IdemRelease("POST./orders.." + key)
+
+	do := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(key)
+	assert.Equal(t, `{"code":0,"data":1,"error":""}`, w.Body.String())
+	assert.Equal(t, "", w.Header().Get(IdempotencyReplayedHeader))
+
+	// replay
+	w = do(key)
+	assert.Equal(t, `{"code":0,"data":1,"error":""}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(IdempotencyReplayedHeader))
+
+	// without key not guarded
+	w = do("")
+	assert.Equal(t, `{"code":0,"data":2,"error":""}`, w.Body.String())
+
+	// in progress
+	cache.IdemReserve("POST./orders..inprogress", time.Second)
+	defer cache.IdemRelease("POST./orders..inprogress")
+	w = do("inprogress")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `{"code":40900,"data":null,"error":"Duplicate Request"}`, w.Body.String())
+}
+
+func TestIdempotencyMiddlewareUserScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		c.Set(sessionContextKey, &cache.Session{UserID: c.GetHeader("X-User")})
+	}, IdempotencyMiddleware(time.Second, time.Second), func(c *gin.Context) {
+		SuccessResponse(c, GetSession(c).UserID)
+	})
+
+	key := GenUUIDV4()
+	defer cache.IdemRelease("POST./orders.alice." + key)
+	defer cache.IdemRelease("POST./orders.bob." + key)
+
+	do := func(user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("X-User", user)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("alice")
+	assert.Equal(t, `{"code":0,"data":"alice","error":""}`, w.Body.String())
+
+	// same key of another user not replayed
+	w = do("bob")
+	assert.Equal(t, `{"code":0,"data":"bob","error":""}`, w.Body.String())
+	assert.Equal(t, "", w.Header().Get(IdempotencyReplayedHeader))
+
+	w = do("alice")
+	assert.Equal(t, `{"code":0,"data":"alice","error":""}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(IdempotencyReplayedHeader))
+}
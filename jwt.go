@@ -0,0 +1,491 @@
+package cbl
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+// -----------------------------------------------------------------------------
+// JWT (RFC 7519) access/refresh tokens, HS256/RS256/ES256, standard library only.
+//
+//	m, _ := cbl.NewJWTManager(&cbl.JWTOptions{Algorithm: cbl.JWTHS256, Key: []byte(secret), Revocation: true})
+//
+//	// login, e.g. after Code2Session
+//	wx, _ := cbl.Code2Session(appID, secret, code)
+//	pair, _ := m.Issue(&cbl.JWTClaims{Subject: userID, OpenID: wx.OpenID, UnionID: wx.UnionID})
+//
+//	auth := router.Group("/", m.Middleware())
+//	auth.GET("/me", func(c *gin.Context) {
+//		claims := cbl.GetJWTClaims(c)
+//	})
+//
+// revocation list stored in cache (module revoke, by jti and sub), expired with the token,
+// checked on every verify when enabled.
+// -----------------------------------------------------------------------------
+
+const (
+	JWTHS256 = "HS256" // HMAC SHA-256, Key []byte
+	JWTRS256 = "RS256" // RSA PKCS#1 v1.5 SHA-256, Key *rsa.PrivateKey (or *rsa.PublicKey verify only)
+	JWTES256 = "ES256" // ECDSA P-256 SHA-256, Key *ecdsa.PrivateKey (or *ecdsa.PublicKey verify only)
+)
+
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+var (
+	ErrTokenInvalid = errors.New("token invalid")
+	ErrTokenExpired = errors.New("token expired")
+	ErrTokenRevoked = errors.New("token revoked")
+	ErrTokenType    = errors.New("token type mismatch")
+	ErrJWTVerifyKey = errors.New("jwt key can only verify")
+)
+
+const jwtClaimsContextKey = "_cbl_jwt_claims_"
+
+type jwtCtxKey struct{}
+
+// JWTClaims registered claims and claims of mini program login
+type JWTClaims struct {
+	ID        string                 `json:"jti"`
+	Issuer    string                 `json:"iss,omitempty"`
+	Subject   string                 `json:"sub"` // user id
+	Audience  string                 `json:"aud,omitempty"`
+	IssuedAt  int64                  `json:"iat"`
+	IssuedMs  int64                  `json:"iat_ms,omitempty"` // iat in milliseconds, for RevokeUser
+	NotBefore int64                  `json:"nbf,omitempty"`
+	ExpiresAt int64                  `json:"exp"`
+	TokenType string                 `json:"token_type"` // TokenAccess or TokenRefresh
+	OpenID    string                 `json:"openid,omitempty"`
+	UnionID   string                 `json:"unionid,omitempty"`
+	Scopes    []string               `json:"scopes,omitempty"`
+	Extra     map[string]interface{} `json:"ext,omitempty"`
+}
+
+// HasScopes claims include all scopes
+func (claims *JWTClaims) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		found := false
+		for _, have := range claims.Scopes {
+			if have == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TokenPair issued tokens, response of login/refresh
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"` // Bearer
+	ExpiresIn        int64  `json:"expires_in"` // seconds of access token
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// JWTOptions options of NewJWTManager
+type JWTOptions struct {
+	Algorithm  string        // JWTHS256 (default), JWTRS256, JWTES256
+	Key        interface{}   // signing key, see Algorithm constants
+	Issuer     string        // `iss` set and verified if not empty
+	Audience   string        // `aud` set and verified if not empty
+	AccessTTL  time.Duration // 2h by default
+	RefreshTTL time.Duration // 30 days by default
+	Leeway     time.Duration // clock skew tolerance of exp/nbf
+	Revocation bool          // check revocation list in cache on verify
+}
+
+// JWTManager issue and verify tokens
+type JWTManager struct {
+	opts   JWTOptions
+	header string // encoded header
+	sign   func(data []byte) ([]byte, error)
+	verify func(data []byte, sig []byte) bool
+}
+
+// NewJWTManager create manager, error if key not match algorithm
+func NewJWTManager(opts *JWTOptions) (*JWTManager, error) {
+	m := &JWTManager{opts: *opts}
+	if m.opts.Algorithm == "" {
+		m.opts.Algorithm = JWTHS256
+	}
+	if m.opts.AccessTTL <= 0 {
+		m.opts.AccessTTL = 2 * time.Hour
+	}
+	if m.opts.RefreshTTL <= 0 {
+		m.opts.RefreshTTL = 30 * 24 * time.Hour
+	}
+	if err := m.initAlgorithm(); err != nil {
+		return nil, err
+	}
+	header, _ := json.Marshal(map[string]string{"alg": m.opts.Algorithm, "typ": "JWT"})
+	m.header = base64.RawURLEncoding.EncodeToString(header)
+	return m, nil
+}
+
+func (m *JWTManager) initAlgorithm() error {
+	verifyOnly := func([]byte) ([]byte, error) {
+		return nil, ErrJWTVerifyKey
+	}
+
+	switch m.opts.Algorithm {
+	case JWTHS256:
+		key, ok := m.opts.Key.([]byte)
+		if !ok || len(key) == 0 {
+			return fmt.Errorf("jwt %s key must be non-empty []byte", JWTHS256)
+		}
+		m.sign = func(data []byte) ([]byte, error) {
+			h := hmac.New(sha256.New, key)
+			h.Write(data)
+			return h.Sum(nil), nil
+		}
+		m.verify = func(data []byte, sig []byte) bool {
+			expected, _ := m.sign(data)
+			return hmac.Equal(expected, sig)
+		}
+	case JWTRS256:
+		var pub *rsa.PublicKey
+		m.sign = verifyOnly
+		switch key := m.opts.Key.(type) {
+		case *rsa.PrivateKey:
+			pub = &key.PublicKey
+			m.sign = func(data []byte) ([]byte, error) {
+				digest := sha256.Sum256(data)
+				return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+			}
+		case *rsa.PublicKey:
+			pub = key
+		default:
+			return fmt.Errorf("jwt %s key must be *rsa.PrivateKey or *rsa.PublicKey", JWTRS256)
+		}
+		m.verify = func(data []byte, sig []byte) bool {
+			digest := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+		}
+	case JWTES256:
+		var pub *ecdsa.PublicKey
+		m.sign = verifyOnly
+		switch key := m.opts.Key.(type) {
+		case *ecdsa.PrivateKey:
+			pub = &key.PublicKey
+			m.sign = func(data []byte) ([]byte, error) {
+				digest := sha256.Sum256(data)
+				r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+				if err != nil {
+					return nil, err
+				}
+				// JWS signature is fixed size R || S
+				sig := make([]byte, 64)
+				rb, sb := r.Bytes(), s.Bytes()
+				copy(sig[32-len(rb):32], rb)
+				copy(sig[64-len(sb):], sb)
+				return sig, nil
+			}
+		case *ecdsa.PublicKey:
+			pub = key
+		default:
+			return fmt.Errorf("jwt %s key must be *ecdsa.PrivateKey or *ecdsa.PublicKey", JWTES256)
+		}
+		if pub.Curve != elliptic.P256() {
+			return fmt.Errorf("jwt %s key must be P-256 curve", JWTES256)
+		}
+		m.verify = func(data []byte, sig []byte) bool {
+			if len(sig) != 64 {
+				return false
+			}
+			digest := sha256.Sum256(data)
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			return ecdsa.Verify(pub, digest[:], r, s)
+		}
+	default:
+		return fmt.Errorf("jwt algorithm %q not supported", m.opts.Algorithm)
+	}
+	return nil
+}
+
+// Sign sign claims as is (no default claims set)
+func (m *JWTManager) Sign(claims *JWTClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	data := m.header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := m.sign([]byte(data))
+	if err != nil {
+		return "", err
+	}
+	return data + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Issue issue access and refresh token of claims (Subject, OpenID, UnionID, Scopes, Extra),
+// jti, iss, aud, iat, exp and token type set
+func (m *JWTManager) Issue(claims *JWTClaims) (*TokenPair, error) {
+	now := time.Now()
+	access, err := m.Sign(m.newClaims(claims, TokenAccess, now, m.opts.AccessTTL))
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.Sign(m.newClaims(claims, TokenRefresh, now, m.opts.RefreshTTL))
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(m.opts.AccessTTL / time.Second),
+		RefreshExpiresIn: int64(m.opts.RefreshTTL / time.Second),
+	}, nil
+}
+
+func (m *JWTManager) newClaims(tmpl *JWTClaims, tokenType string, now time.Time, ttl time.Duration) *JWTClaims {
+	claims := *tmpl
+	claims.ID = GenUUIDV4()
+	claims.Issuer = m.opts.Issuer
+	claims.Audience = m.opts.Audience
+	claims.IssuedAt = now.Unix()
+	claims.IssuedMs = now.UnixNano() / int64(time.Millisecond)
+	claims.NotBefore = 0
+	claims.ExpiresAt = now.Add(ttl).Unix()
+	claims.TokenType = tokenType
+	return &claims
+}
+
+// Verify verify token signature, exp/nbf, iss/aud and revocation (if enabled).
+// ErrTokenInvalid, ErrTokenExpired, ErrTokenRevoked, or cache error
+func (m *JWTManager) Verify(token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenInvalid
+	}
+	// header must be ours exactly, no algorithm confusion
+	if parts[0] != m.header {
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, ErrTokenInvalid
+		}
+		var h struct {
+			Alg string `json:"alg"`
+		}
+		if json.Unmarshal(header, &h) != nil || h.Alg != m.opts.Algorithm {
+			return nil, ErrTokenInvalid
+		}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !m.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	claims := &JWTClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(m.opts.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(m.opts.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrTokenInvalid
+	}
+	if m.opts.Issuer != "" && claims.Issuer != m.opts.Issuer {
+		return nil, ErrTokenInvalid
+	}
+	if m.opts.Audience != "" && claims.Audience != m.opts.Audience {
+		return nil, ErrTokenInvalid
+	}
+
+	if m.opts.Revocation {
+		revoked, err := m.isRevoked(claims)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// Refresh verify refresh token and issue new pair. if revocation enabled, the refresh token
+// revoked (rotation) atomically, concurrent refreshes by the same token only one succeed,
+// others ErrTokenRevoked
+func (m *JWTManager) Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := m.Verify(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenRefresh {
+		return nil, ErrTokenType
+	}
+	if m.opts.Revocation {
+		ttl := m.revokeTTL(claims)
+		if ttl <= 0 {
+			return nil, ErrTokenExpired
+		}
+		ok, err := cache.RevokeToken(claims.ID, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return m.Issue(claims)
+}
+
+// Revoke revoke token until it expired, e.g. logout. Revocation must be enabled to take effect
+func (m *JWTManager) Revoke(claims *JWTClaims) error {
+	ttl := m.revokeTTL(claims)
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := cache.RevokeToken(claims.ID, ttl)
+	return err
+}
+
+// RevokeUser revoke all tokens of user issued before now, e.g. password changed.
+// compared in milliseconds (`iat_ms`), returns after current millisecond passed, tokens
+// issued after it returns are valid. tokens without `iat_ms` compared in seconds.
+func (m *JWTManager) RevokeUser(subject string) error {
+	now := time.Now()
+	if err := cache.RevokeSubject(subject, now, m.opts.RefreshTTL+m.opts.Leeway); err != nil {
+		return err
+	}
+	time.Sleep(time.Until(now.Truncate(time.Millisecond).Add(time.Millisecond)))
+	return nil
+}
+
+func (m *JWTManager) revokeTTL(claims *JWTClaims) time.Duration {
+	return time.Until(time.Unix(claims.ExpiresAt, 0)) + m.opts.Leeway
+}
+
+func (m *JWTManager) isRevoked(claims *JWTClaims) (bool, error) {
+	revoked, err := cache.TokenRevoked(claims.ID)
+	if err != nil || revoked {
+		return revoked, err
+	}
+	at, err := cache.SubjectRevokedAt(claims.Subject)
+	if err == cache.NotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if claims.IssuedMs > 0 {
+		return claims.IssuedMs <= at.UnixNano()/int64(time.Millisecond), nil
+	}
+	return claims.IssuedAt <= at.Unix(), nil
+}
+
+// Middleware verify access token of header `Authorization: Bearer <token>`.
+// ErrorLoginRequired if missing, invalid, expired or revoked, ErrorPermissionDenied if not
+// an access token or lack of any scope. claims stored in gin and request context.
+func (m *JWTManager) Middleware(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer`)
+			ErrorResponse(c, ErrorLoginRequired)
+			c.Abort()
+			return
+		}
+
+		claims, err := m.Verify(token)
+		if err == ErrTokenInvalid || err == ErrTokenExpired || err == ErrTokenRevoked {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ErrorResponse(c, ErrorLoginRequired.Wrap(err))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			ErrorResponse(c, ErrorInternalServerError.Wrap(err))
+			c.Abort()
+			return
+		}
+		if claims.TokenType != TokenAccess || !claims.HasScopes(scopes...) {
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			ErrorResponse(c, ErrorPermissionDenied)
+			c.Abort()
+			return
+		}
+
+		c.Set(jwtClaimsContextKey, claims)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), jwtCtxKey{}, claims))
+		c.Next()
+	}
+}
+
+func bearerToken(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) <= len(prefix) || strings.ToLower(authorization[:len(prefix)]) != prefix {
+		return ""
+	}
+	return strings.TrimSpace(authorization[len(prefix):])
+}
+
+// GetJWTClaims claims verified by JWTManager.Middleware, nil if not authenticated
+func GetJWTClaims(c *gin.Context) *JWTClaims {
+	v, ok := c.Get(jwtClaimsContextKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := v.(*JWTClaims)
+	return claims
+}
+
+// JWTClaimsFromContext claims in request context, nil if not authenticated
+func JWTClaimsFromContext(ctx context.Context) *JWTClaims {
+	claims, _ := ctx.Value(jwtCtxKey{}).(*JWTClaims)
+	return claims
+}
+
+// ParseJWTKeyPEM parse PEM key for JWTOptions.Key: RSA/EC private key (PKCS#1, SEC 1, PKCS#8)
+// or public key (PKIX)
+func ParseJWTKeyPEM(bs []byte) (interface{}, error) {
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, errors.New("jwt key pem invalid")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("jwt key pem type %q not supported", block.Type)
+}
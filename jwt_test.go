@@ -0,0 +1,216 @@
+package cbl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+func TestJWTAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	for _, opts := range []*JWTOptions{
+		{Algorithm: JWTHS256, Key: []byte("secret")},
+		{Algorithm: JWTRS256, Key: rsaKey},
+		{Algorithm: JWTES256, Key: ecKey},
+	} {
+		m, err := NewJWTManager(opts)
+		require.Nil(t, err, opts.Algorithm)
+		pair, err := m.Issue(&JWTClaims{Subject: "u1", OpenID: "o1", UnionID: "un1", Scopes: []string{"order"}})
+		require.Nil(t, err, opts.Algorithm)
+		assert.Equal(t, int64(7200), pair.ExpiresIn)
+
+		claims, err := m.Verify(pair.AccessToken)
+		require.Nil(t, err, opts.Algorithm)
+		assert.Equal(t, "u1", claims.Subject)
+		assert.Equal(t, "o1", claims.OpenID)
+		assert.Equal(t, "un1", claims.UnionID)
+		assert.Equal(t, TokenAccess, claims.TokenType)
+		assert.True(t, claims.HasScopes("order"))
+		assert.False(t, claims.HasScopes("order", "admin"))
+
+		// tampered payload
+		parts := strings.Split(pair.AccessToken, ".")
+		other, _ := m.Sign(&JWTClaims{Subject: "u2", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		_, err = m.Verify(parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2])
+		assert.Equal(t, ErrTokenInvalid, err, opts.Algorithm)
+	}
+
+	// verify only by public key
+	signer, _ := NewJWTManager(&JWTOptions{Algorithm: JWTES256, Key: ecKey})
+	verifier, err := NewJWTManager(&JWTOptions{Algorithm: JWTES256, Key: &ecKey.PublicKey})
+	require.Nil(t, err)
+	pair, _ := signer.Issue(&JWTClaims{Subject: "u1"})
+	_, err = verifier.Verify(pair.AccessToken)
+	assert.Nil(t, err)
+	_, err = verifier.Issue(&JWTClaims{Subject: "u1"})
+	assert.Equal(t, ErrJWTVerifyKey, err)
+
+	// algorithm confusion
+	hs, _ := NewJWTManager(&JWTOptions{Algorithm: JWTHS256, Key: []byte("secret")})
+	token, _ := hs.Sign(&JWTClaims{Subject: "u1", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	_, err = verifier.Verify(token)
+	assert.Equal(t, ErrTokenInvalid, err)
+
+	_, err = NewJWTManager(&JWTOptions{Algorithm: JWTRS256, Key: []byte("secret")})
+	assert.NotNil(t, err)
+	_, err = NewJWTManager(&JWTOptions{Algorithm: "none"})
+	assert.NotNil(t, err)
+}
+
+func TestJWTClaimsValidation(t *testing.T) {
+	m, _ := NewJWTManager(&JWTOptions{Key: []byte("secret"), Issuer: "cbl", Leeway: time.Minute})
+	now := time.Now()
+
+	token, _ := m.Sign(&JWTClaims{Issuer: "cbl", ExpiresAt: now.Add(-30 * time.Second).Unix()})
+	_, err := m.Verify(token) // within leeway
+	assert.Nil(t, err)
+	token, _ = m.Sign(&JWTClaims{Issuer: "cbl", ExpiresAt: now.Add(-2 * time.Minute).Unix()})
+	_, err = m.Verify(token)
+	assert.Equal(t, ErrTokenExpired, err)
+	token, _ = m.Sign(&JWTClaims{Issuer: "other", ExpiresAt: now.Add(time.Hour).Unix()})
+	_, err = m.Verify(token)
+	assert.Equal(t, ErrTokenInvalid, err)
+	token, _ = m.Sign(&JWTClaims{Issuer: "cbl", NotBefore: now.Add(time.Hour).Unix(), ExpiresAt: now.Add(2 * time.Hour).Unix()})
+	_, err = m.Verify(token)
+	assert.Equal(t, ErrTokenInvalid, err)
+	_, err = m.Verify("a.b")
+	assert.Equal(t, ErrTokenInvalid, err)
+}
+
+func TestJWTRevocation(t *testing.T) {
+	m, _ := NewJWTManager(&JWTOptions{Key: []byte("secret"), Revocation: true})
+	defer cache.Purge(cache.ModuleRevoke, "s.TestJWTRevocationUser", 1)
+
+	pair, err := m.Issue(&JWTClaims{Subject: "TestJWTRevocationUser"})
+	require.Nil(t, err)
+	_, err = m.Refresh(pair.AccessToken)
+	assert.Equal(t, ErrTokenType, err)
+
+	// refresh rotation
+	newPair, err := m.Refresh(pair.RefreshToken)
+	require.Nil(t, err)
+	_, err = m.Refresh(pair.RefreshToken)
+	assert.Equal(t, ErrTokenRevoked, err)
+
+	claims, err := m.Verify(newPair.AccessToken)
+	require.Nil(t, err)
+	require.Nil(t, m.Revoke(claims))
+	_, err = m.Verify(newPair.AccessToken)
+	assert.Equal(t, ErrTokenRevoked, err)
+
+	// all tokens of user
+	_, err = m.Verify(newPair.RefreshToken)
+	require.Nil(t, err)
+	require.Nil(t, m.RevokeUser("TestJWTRevocationUser"))
+	_, err = m.Verify(newPair.RefreshToken)
+	assert.Equal(t, ErrTokenRevoked, err)
+
+	// issued right after revoking, same second
+	pair, err = m.Issue(&JWTClaims{Subject: "TestJWTRevocationUser"})
+	require.Nil(t, err)
+	_, err = m.Verify(pair.AccessToken)
+	assert.Nil(t, err)
+	_, err = m.Refresh(pair.RefreshToken)
+	assert.Nil(t, err)
+}
+
+func TestJWTRefreshConcurrent(t *testing.T) {
+	m, _ := NewJWTManager(&JWTOptions{Key: []byte("secret"), Revocation: true})
+	pair, err := m.Issue(&JWTClaims{Subject: "TestJWTRefreshConcurrentUser"})
+	require.Nil(t, err)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Refresh(pair.RefreshToken); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			} else {
+				assert.Equal(t, ErrTokenRevoked, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, succeeded)
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	m, _ := NewJWTManager(&JWTOptions{Key: []byte("secret")})
+	pair, _ := m.Issue(&JWTClaims{Subject: "u1", Scopes: []string{"order:read"}})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/me", m.Middleware(), func(c *gin.Context) {
+		SuccessResponse(c, GetJWTClaims(c).Subject+"/"+JWTClaimsFromContext(c.Request.Context()).Subject)
+	})
+	router.GET("/admin", m.Middleware("admin"), func(c *gin.Context) {
+		SuccessResponse(c, nil)
+	})
+
+	do := func(path string, authorization string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/me", "Bearer "+pair.AccessToken)
+	assert.Equal(t, `{"code":0,"data":"u1/u1","error":""}`, w.Body.String())
+
+	w = do("/me", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"code":40100,"data":null,"error":"Login Required"}`, w.Body.String())
+	w = do("/me", "Bearer bad.token.value")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+	w = do("/me", "Bearer "+pair.RefreshToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, `{"code":40300,"data":null,"error":"Permission Denied"}`, w.Body.String())
+	w = do("/admin", "bearer "+pair.AccessToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestParseJWTKeyPEM(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(ecKey)
+	key, err := ParseJWTKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.Nil(t, err)
+	_, err = NewJWTManager(&JWTOptions{Algorithm: JWTES256, Key: key})
+	assert.Nil(t, err)
+
+	der, _ = x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	key, err = ParseJWTKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.Nil(t, err)
+	_, ok := key.(*ecdsa.PublicKey)
+	assert.True(t, ok)
+
+	_, err = ParseJWTKeyPEM([]byte("not pem"))
+	assert.NotNil(t, err)
+}
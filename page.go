@@ -0,0 +1,148 @@
+package cbl
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -----------------------------------------------------------------------------
+// pagination, page/size or cursor/size from query, page response in Resp.Data:
+//
+//	{"items":[...],"total":100,"has_more":true,"next_cursor":"..."}
+//
+//	q, err := cbl.ParsePage(c) // ?page=2&size=20
+//	if err != nil {
+//		cbl.ErrorResponse(c, err)
+//		return
+//	}
+//	orders, total := svc.ListOrders(q.Offset(), q.Size)
+//	cbl.SuccessPage(c, orders, total, q.HasMore(total), "")
+// -----------------------------------------------------------------------------
+
+var (
+	PageSizeDefault = 20  // size if not set
+	PageSizeMax     = 100 // larger size clamped to
+	pageMax         = 1 << 20
+)
+
+// PageQuery page-based request, page start from 1
+type PageQuery struct {
+	Page int `json:"page"`
+	Size int `json:"size"`
+}
+
+// Offset items skipped
+func (q *PageQuery) Offset() int {
+	return (q.Page - 1) * q.Size
+}
+
+// HasMore more pages after this one
+func (q *PageQuery) HasMore(total int64) bool {
+	return int64(q.Offset()+q.Size) < total
+}
+
+// CursorQuery cursor-based request, empty cursor for first page
+type CursorQuery struct {
+	Cursor string `json:"cursor"`
+	Size   int    `json:"size"`
+}
+
+// Page page response, Resp.Data of SuccessPage
+type Page struct {
+	Items      interface{} `json:"items"`
+	Total      int64       `json:"total"`
+	HasMore    bool        `json:"has_more"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// ParsePage parse `page` (default 1) and `size` from query, size clamped to PageSizeMax,
+// ErrorOutOfRange if not positive integer
+func ParsePage(c *gin.Context) (*PageQuery, error) {
+	page, err := queryPositive(c, "page", 1)
+	if err != nil {
+		return nil, err
+	}
+	if page > pageMax {
+		return nil, ErrorOutOfRange.Wrap(fmt.Errorf("page %d exceed %d", page, pageMax))
+	}
+	size, err := pageSize(c)
+	if err != nil {
+		return nil, err
+	}
+	return &PageQuery{Page: page, Size: size}, nil
+}
+
+// ParseCursor parse `cursor` and `size` from query, size clamped to PageSizeMax,
+// ErrorOutOfRange if size not positive integer
+func ParseCursor(c *gin.Context) (*CursorQuery, error) {
+	size, err := pageSize(c)
+	if err != nil {
+		return nil, err
+	}
+	return &CursorQuery{Cursor: c.Query("cursor"), Size: size}, nil
+}
+
+// SuccessPage page response, nil items as empty list, nextCursor empty for page-based
+func SuccessPage(c *gin.Context, items interface{}, total int64, hasMore bool, nextCursor string) {
+	if items == nil {
+		items = []interface{}{}
+	} else if v := reflect.ValueOf(items); v.Kind() == reflect.Slice && v.IsNil() {
+		items = reflect.MakeSlice(v.Type(), 0, 0).Interface()
+	}
+	SuccessResponse(c, &Page{
+		Items:      items,
+		Total:      total,
+		HasMore:    hasMore,
+		NextCursor: nextCursor,
+	})
+}
+
+// EncodeCursor opaque cursor of v (json, url safe base64), e.g. last item sort keys
+func EncodeCursor(v interface{}) (string, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// DecodeCursor decode cursor by EncodeCursor into v, ErrorOutOfRange if malformed
+func DecodeCursor(cursor string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrorOutOfRange.Wrap(err)
+	}
+	if err := json.Unmarshal(bs, v); err != nil {
+		return ErrorOutOfRange.Wrap(err)
+	}
+	return nil
+}
+
+func pageSize(c *gin.Context) (int, error) {
+	size, err := queryPositive(c, "size", PageSizeDefault)
+	if err != nil {
+		return 0, err
+	}
+	if size > PageSizeMax {
+		size = PageSizeMax
+	}
+	return size, nil
+}
+
+// queryPositive positive integer query param, def if not set
+func queryPositive(c *gin.Context, key string, def int) (int, error) {
+	s := c.Query(key)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, ErrorOutOfRange.Wrap(fmt.Errorf("invalid %s %q", key, s))
+	}
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package cbl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parse := func(query string) (*PageQuery, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(http.MethodGet, "/?"+query, nil)
+		return ParsePage(c)
+	}
+
+	q, err := parse("")
+	require.Nil(t, err)
+	assert.Equal(t, &PageQuery{Page: 1, Size: PageSizeDefault}, q)
+
+	q, err = parse("page=3&size=10")
+	require.Nil(t, err)
+	assert.Equal(t, 20, q.Offset())
+	assert.True(t, q.HasMore(31))
+	assert.False(t, q.HasMore(30))
+
+	q, err = parse("size=1000")
+	require.Nil(t, err)
+	assert.Equal(t, PageSizeMax, q.Size)
+
+	for _, query := range []string{"page=0", "page=-1", "page=x", "size=0", "page=99999999"} {
+		_, err = parse(query)
+		assert.True(t, errors.Is(err, ErrorOutOfRange), query)
+	}
+}
+
+func TestCursorPage(t *testing.T) {
+	type cursor struct {
+		ID int64 `json:"id"`
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/orders", func(c *gin.Context) {
+		q, err := ParseCursor(c)
+		if err != nil {
+			ErrorResponse(c, err)
+			return
+		}
+		var after cursor
+		if q.Cursor != "" {
+			if err := DecodeCursor(q.Cursor, &after); err != nil {
+				ErrorResponse(c, err)
+				return
+			}
+		}
+		if after.ID >= 3 {
+			var none []int64
+			SuccessPage(c, none, 3, false, "")
+			return
+		}
+		next, _ := EncodeCursor(cursor{ID: after.ID + int64(q.Size)})
+		SuccessPage(c, []int64{after.ID + 1}, 3, true, next)
+	})
+
+	do := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/orders?"+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("size=1")
+	assert.Equal(t, `{"code":0,"data":{"items":[1],"total":3,"has_more":true,"next_cursor":"eyJpZCI6MX0"},"error":""}`, w.Body.String())
+	w = do("size=1&cursor=eyJpZCI6M30")
+	assert.Equal(t, `{"code":0,"data":{"items":[],"total":3,"has_more":false},"error":""}`, w.Body.String())
+	w = do("cursor=bad!")
+	assert.Equal(t, `{"code":40004,"data":null,"error":"Out Of Range"}`, w.Body.String())
+	w = do("size=-5")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangjie2012/cbl-go/cache"
 )
 
 var (
@@ -17,10 +18,57 @@ var (
 		},
 		[]string{"api_name", "method"},
 	)
+
+	// cache metrics, collected by PromCacheHook
+	cacheOpLatency = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "cache_operation_latency",
+			Help:       "cache operation latency",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"op"},
+	)
+	cacheOpErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_operation_errors_total",
+			Help: "cache operation errors",
+		},
+		[]string{"op"},
+	)
+	cacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "cache getter hit/miss count",
+		},
+		[]string{"op", "result"},
+	)
+	cacheLockEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lock_events_total",
+			Help: "cache distributed lock acquired/contended/timeout/released count",
+		},
+		[]string{"name", "event"},
+	)
+	cacheLockWait = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "cache_lock_wait_latency",
+			Help:       "cache distributed lock acquire wait latency",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"name"},
+	)
+	cacheMQDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_mq_depth",
+			Help: "cache message queue depth",
+		},
+		[]string{"key"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(apiCalledLatency)
+	prometheus.MustRegister(cacheOpLatency, cacheOpErrors, cacheHits, cacheLockEvents, cacheLockWait, cacheMQDepth)
 }
 
 func removeUriQueryString(uri string) string {
@@ -45,3 +93,60 @@ func PromGinMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+type promCacheHook struct {
+	keyLabel func(key string) string
+}
+
+// PromCacheHook cache hook collect cache metrics, enable it:
+//     cache.AddHook(cbl.PromCacheHook())
+// lock name not labeled (label value empty) and mq depth not collected, keys are unbounded,
+// see PromCacheHookWithKeys
+func PromCacheHook() cache.Hook {
+	return promCacheHook{}
+}
+
+// PromCacheHookWithKeys same as PromCacheHook, lock name and mq key labeled by normalize,
+// normalize must map keys to a small fixed set (e.g. strip ids). mq depth only collected
+// here, queues normalized to empty skipped (depth of different queues not mixed)
+func PromCacheHookWithKeys(normalize func(key string) string) cache.Hook {
+	return promCacheHook{keyLabel: normalize}
+}
+
+func (h promCacheHook) label(key string) string {
+	if h.keyLabel == nil {
+		return ""
+	}
+	return h.keyLabel(key)
+}
+
+func (promCacheHook) Operation(op string, latency time.Duration, err error) {
+	cacheOpLatency.WithLabelValues(op).Observe(float64(latency)) // nanosecond
+	if err != nil {
+		cacheOpErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func (promCacheHook) Hit(op string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheHits.WithLabelValues(op, result).Inc()
+}
+
+func (h promCacheHook) Lock(name string, event cache.LockEvent, wait time.Duration) {
+	name = h.label(name)
+	cacheLockEvents.WithLabelValues(name, event.String()).Inc()
+	if event == cache.LockAcquired || event == cache.LockTimeout {
+		cacheLockWait.WithLabelValues(name).Observe(float64(wait)) // nanosecond
+	}
+}
+
+func (h promCacheHook) MQDepth(key string, depth int64) {
+	key = h.label(key)
+	if key == "" {
+		return
+	}
+	cacheMQDepth.WithLabelValues(key).Set(float64(depth))
+}
@@ -0,0 +1,38 @@
+package cbl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+func TestPromCacheHook(t *testing.T) {
+	h := PromCacheHook()
+
+	h.Operation("TestGet", time.Millisecond, nil)
+	h.Operation("TestGet", time.Millisecond, errors.New("whatever"))
+	h.Hit("TestGet", true)
+	h.Hit("TestGet", false)
+	h.Hit("TestGet", false)
+	h.Lock("TestLock", cache.LockContended, 0)
+	h.Lock("TestLock", cache.LockAcquired, time.Millisecond)
+	h.MQDepth("TestMQ", 10)
+
+	assert.EqualValues(t, 1, testutil.ToFloat64(cacheOpErrors.WithLabelValues("TestGet")))
+	assert.EqualValues(t, 1, testutil.ToFloat64(cacheHits.WithLabelValues("TestGet", "hit")))
+	assert.EqualValues(t, 2, testutil.ToFloat64(cacheHits.WithLabelValues("TestGet", "miss")))
+	assert.EqualValues(t, 1, testutil.ToFloat64(cacheLockEvents.WithLabelValues("", "contended")))
+	assert.Equal(t, 0, testutil.CollectAndCount(cacheMQDepth)) // not collected without normalize
+	assert.EqualValues(t, 0, testutil.ToFloat64(cacheLockEvents.WithLabelValues("TestLock", "contended")))
+
+	h = PromCacheHookWithKeys(func(key string) string { return strings.TrimRight(key, "0123456789") })
+	h.Lock("order.42", cache.LockContended, 0)
+	h.MQDepth("TestMQ", 5)
+	assert.EqualValues(t, 1, testutil.ToFloat64(cacheLockEvents.WithLabelValues("order.", "contended")))
+	assert.EqualValues(t, 5, testutil.ToFloat64(cacheMQDepth.WithLabelValues("TestMQ")))
+}
@@ -0,0 +1,274 @@
+package cbl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+// -----------------------------------------------------------------------------
+// RBAC, role based access control. roles grant permissions (`resource:action`, wildcard
+// `order:*` or `*`) and may inherit other roles, user roles loaded by UserRoles and
+// cached in cache (module rbac).
+//
+//	rbac := cbl.NewRBAC(&cbl.RBACOptions{UserRoles: db.UserRoles, CacheTTL: 5 * time.Minute})
+//	rbac.AddRole("viewer", []string{"order:read"})
+//	rbac.AddRole("clerk", []string{"order:write"}, "viewer")
+//	rbac.AddRole("admin", []string{"*"})
+//
+//	router.POST("/orders", rbac.Require("order:write"), createOrder)
+//	router.PUT("/orders/:id", rbac.RequireOwner(orderOwner, "order:admin"), updateOrder)
+//
+// user of request by SessionManager or JWTManager middleware (see RBACOptions.UserID).
+// -----------------------------------------------------------------------------
+
+// Role role declaration, also config format
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	Inherits    []string `json:"inherits,omitempty"`
+}
+
+// OwnerFunc report whether user owns the resource of request, e.g. order of path `:id`
+type OwnerFunc func(c *gin.Context, userID string) (bool, error)
+
+// RBACOptions options of NewRBAC
+type RBACOptions struct {
+	UserRoles func(userID string) ([]string, error) // load roles of user, e.g. from db. required
+	CacheTTL  time.Duration                         // cache roles of user, 0 not cached
+	UserID    func(c *gin.Context) string           // user of request, session user or jwt subject if nil
+}
+
+// RBAC roles and permissions checker
+type RBAC struct {
+	opts RBACOptions
+
+	mu    sync.RWMutex
+	roles map[string]*Role
+
+	contextKey string // request roles in gin context, per instance
+}
+
+var rbacInstances int64
+
+// NewRBAC create RBAC, roles declared by AddRole or LoadRoles
+func NewRBAC(opts *RBACOptions) *RBAC {
+	r := &RBAC{
+		opts:       *opts,
+		roles:      map[string]*Role{},
+		contextKey: fmt.Sprintf("_cbl_rbac_roles_%d_", atomic.AddInt64(&rbacInstances, 1)),
+	}
+	if r.opts.UserID == nil {
+		r.opts.UserID = requestUserID
+	}
+	return r
+}
+
+// AddRole declare (replace) role
+func (r *RBAC) AddRole(name string, permissions []string, inherits ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[name] = &Role{Name: name, Permissions: permissions, Inherits: inherits}
+}
+
+// LoadRoles replace all roles by json config, e.g.
+//
+//	[{"name":"viewer","permissions":["order:read"]},{"name":"clerk","permissions":["order:write"],"inherits":["viewer"]}]
+func (r *RBAC) LoadRoles(bs []byte) error {
+	var roles []*Role
+	if err := json.Unmarshal(bs, &roles); err != nil {
+		return err
+	}
+	m := make(map[string]*Role, len(roles))
+	for _, role := range roles {
+		if role.Name == "" {
+			return fmt.Errorf("rbac role name empty")
+		}
+		m[role.Name] = role
+	}
+	for _, role := range roles {
+		for _, parent := range role.Inherits {
+			if _, ok := m[parent]; !ok {
+				return fmt.Errorf("rbac role %s inherits undeclared role %s", role.Name, parent)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles = m
+	return nil
+}
+
+// HasPermission any of roles (or inherited) grants permission
+func (r *RBAC) HasPermission(roles []string, permission string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	visited := map[string]bool{}
+	for _, name := range roles {
+		if r.grants(name, permission, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// grants must hold lock, visited guard inheritance cycle
+func (r *RBAC) grants(name string, permission string, visited map[string]bool) bool {
+	if visited[name] {
+		return false
+	}
+	visited[name] = true
+	role, ok := r.roles[name]
+	if !ok {
+		return false
+	}
+	for _, p := range role.Permissions {
+		if matchPermission(p, permission) {
+			return true
+		}
+	}
+	for _, parent := range role.Inherits {
+		if r.grants(parent, permission, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPermission granted `*`, `order:*` or exactly
+func matchPermission(granted string, permission string) bool {
+	if granted == "*" || granted == permission {
+		return true
+	}
+	return strings.HasSuffix(granted, ":*") && strings.HasPrefix(permission, granted[:len(granted)-1])
+}
+
+// UserRoles roles of user, cached if CacheTTL set. cache unavailable (e.g. circuit open)
+// loaded by UserRoles every time, stale roles never used.
+func (r *RBAC) UserRoles(userID string) ([]string, error) {
+	if r.opts.CacheTTL <= 0 {
+		return r.opts.UserRoles(userID)
+	}
+
+	roles, err := cache.RBACRolesGet(userID)
+	if err == nil {
+		return roles, nil
+	}
+	cached := err == cache.NotExist
+	roles, err = r.opts.UserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		// cache failure not block the check
+		cache.RBACRolesSet(userID, roles, r.opts.CacheTTL)
+	}
+	return roles, nil
+}
+
+// InvalidateUser remove cached roles of user, call after user roles changed
+func (r *RBAC) InvalidateUser(userID string) error {
+	return cache.RBACRolesDel(userID)
+}
+
+// Can user has permission
+func (r *RBAC) Can(userID string, permission string) (bool, error) {
+	roles, err := r.UserRoles(userID)
+	if err != nil {
+		return false, err
+	}
+	return r.HasPermission(roles, permission), nil
+}
+
+// requestRoles roles of request user, looked up once per request
+func (r *RBAC) requestRoles(c *gin.Context, userID string) ([]string, error) {
+	if v, ok := c.Get(r.contextKey); ok {
+		if roles, ok := v.([]string); ok {
+			return roles, nil
+		}
+	}
+	roles, err := r.UserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(r.contextKey, roles)
+	return roles, nil
+}
+
+// Require user must have all permissions. ErrorLoginRequired if no user, ErrorPermissionDenied if not granted
+func (r *RBAC) Require(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := r.opts.UserID(c)
+		if userID == "" {
+			ErrorResponse(c, ErrorLoginRequired)
+			c.Abort()
+			return
+		}
+		roles, err := r.requestRoles(c, userID)
+		if err != nil {
+			ErrorResponse(c, ErrorInternalServerError.Wrap(err))
+			c.Abort()
+			return
+		}
+		for _, p := range permissions {
+			if !r.HasPermission(roles, p) {
+				ErrorResponse(c, ErrorPermissionDenied)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireOwner user must own the resource, or have any of `bypass` permissions (e.g. `order:admin`).
+// ErrorLoginRequired if no user, ErrorPermissionDenied if neither, error of owner responded by ErrorResponse
+// (not *Error ones as ErrorInternalServerError)
+func (r *RBAC) RequireOwner(owner OwnerFunc, bypass ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := r.opts.UserID(c)
+		if userID == "" {
+			ErrorResponse(c, ErrorLoginRequired)
+			c.Abort()
+			return
+		}
+
+		if len(bypass) > 0 {
+			roles, err := r.requestRoles(c, userID)
+			if err != nil {
+				ErrorResponse(c, ErrorInternalServerError.Wrap(err))
+				c.Abort()
+				return
+			}
+			for _, p := range bypass {
+				if r.HasPermission(roles, p) {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		ok, err := owner(c, userID)
+		if err != nil {
+			if _, isError := AsError(err); !isError {
+				err = ErrorInternalServerError.Wrap(err)
+			}
+			ErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+		if !ok {
+			ErrorResponse(c, ErrorPermissionDenied)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,146 @@
+package cbl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBACPermission(t *testing.T) {
+	rbac := NewRBAC(&RBACOptions{UserRoles: func(userID string) ([]string, error) { return nil, nil }})
+	require.Nil(t, rbac.LoadRoles([]byte(`[
+		{"name":"viewer","permissions":["order:read"]},
+		{"name":"clerk","permissions":["order:write","stock:*"],"inherits":["viewer"]},
+		{"name":"loop","permissions":[],"inherits":["loop2"]},
+		{"name":"loop2","permissions":[],"inherits":["loop"]}
+	]`)))
+	rbac.AddRole("admin", []string{"*"})
+
+	assert.True(t, rbac.HasPermission([]string{"viewer"}, "order:read"))
+	assert.False(t, rbac.HasPermission([]string{"viewer"}, "order:write"))
+	assert.True(t, rbac.HasPermission([]string{"clerk"}, "order:read"))
+	assert.True(t, rbac.HasPermission([]string{"clerk"}, "stock:adjust"))
+	assert.False(t, rbac.HasPermission([]string{"clerk"}, "stockpile:read"))
+	assert.True(t, rbac.HasPermission([]string{"unknown", "admin"}, "user:delete"))
+	assert.False(t, rbac.HasPermission([]string{"loop"}, "order:read"))
+	assert.False(t, rbac.HasPermission(nil, "order:read"))
+
+	assert.NotNil(t, rbac.LoadRoles([]byte(`[{"name":"a","inherits":["missing"]}]`)))
+	assert.NotNil(t, rbac.LoadRoles([]byte(`[{"permissions":["*"]}]`)))
+}
+
+func TestRBACCachedRoles(t *testing.T) {
+	var loads int64
+	rbac := NewRBAC(&RBACOptions{
+		UserRoles: func(userID string) ([]string, error) {
+			atomic.AddInt64(&loads, 1)
+			return []string{"viewer"}, nil
+		},
+		CacheTTL: time.Minute,
+	})
+	rbac.AddRole("viewer", []string{"order:read"})
+	userID := "TestRBACCachedRolesUser"
+	defer rbac.InvalidateUser(userID)
+
+	for i := 0; i < 3; i++ {
+		ok, err := rbac.Can(userID, "order:read")
+		require.Nil(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&loads))
+
+	require.Nil(t, rbac.InvalidateUser(userID))
+	ok, err := rbac.Can(userID, "order:write")
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&loads))
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	userRoles := map[string][]string{"alice": {"clerk"}, "bob": {"viewer"}, "root": {"admin"}}
+	rbac := NewRBAC(&RBACOptions{
+		UserRoles: func(userID string) ([]string, error) {
+			if userID == "broken" {
+				return nil, errors.New("db down")
+			}
+			return userRoles[userID], nil
+		},
+		UserID: func(c *gin.Context) string { return c.GetHeader("X-User") },
+	})
+	rbac.AddRole("viewer", []string{"order:read"})
+	rbac.AddRole("clerk", []string{"order:write"}, "viewer")
+	rbac.AddRole("admin", []string{"order:*"})
+
+	orderOwner := func(c *gin.Context, userID string) (bool, error) {
+		switch c.Param("id") {
+		case "missing":
+			return false, ErrorNotFound
+		case "1":
+			return userID == "bob", nil
+		}
+		return false, nil
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", rbac.Require("order:write"), func(c *gin.Context) {
+		SuccessResponse(c, nil)
+	})
+	router.PUT("/orders/:id", rbac.RequireOwner(orderOwner, "order:admin"), func(c *gin.Context) {
+		SuccessResponse(c, nil)
+	})
+
+	do := func(method string, path string, user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("X-User", user)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/orders", "alice").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/orders", "root").Code)
+	w := do(http.MethodPost, "/orders", "bob")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, `{"code":40300,"data":null,"error":"Permission Denied"}`, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/orders", "").Code)
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodPost, "/orders", "broken").Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/orders/1", "bob").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/orders/1", "alice").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/orders/2", "root").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/orders/missing", "alice").Code)
+}
+
+func TestRBACInstances(t *testing.T) {
+	userID := func(c *gin.Context) string { return "alice" }
+	shop := NewRBAC(&RBACOptions{
+		UserRoles: func(userID string) ([]string, error) { return []string{"shop"}, nil },
+		UserID:    userID,
+	})
+	shop.AddRole("shop", []string{"order:read"})
+	billing := NewRBAC(&RBACOptions{
+		UserRoles: func(userID string) ([]string, error) { return []string{"billing"}, nil },
+		UserID:    userID,
+	})
+	billing.AddRole("billing", []string{"invoice:read"})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// roles of one instance not reused by the other in the same request
+	router.GET("/invoices", shop.Require("order:read"), billing.Require("invoice:read"), func(c *gin.Context) {
+		SuccessResponse(c, nil)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/invoices", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
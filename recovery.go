@@ -0,0 +1,85 @@
+package cbl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecoveryOptions options of RecoveryMiddleware
+type RecoveryOptions struct {
+	Writer io.Writer                                                 // panic log output, gin.DefaultErrorWriter if nil
+	Report func(c *gin.Context, recovered interface{}, stack []byte) // optional, report panic e.g. to sentry
+}
+
+// RecoveryMiddleware recover panic, log it with stack, request id and route, then response
+// ErrorInternalServerError in Resp envelope (instead of bare 500 by gin.Recovery).
+// nothing responded if response already written or client connection broken.
+func RecoveryMiddleware(opts *RecoveryOptions) gin.HandlerFunc {
+	var (
+		w      io.Writer = gin.DefaultErrorWriter
+		report func(c *gin.Context, recovered interface{}, stack []byte)
+	)
+	if opts != nil {
+		if opts.Writer != nil {
+			w = opts.Writer
+		}
+		report = opts.Report
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			stack := debug.Stack()
+			fmt.Fprintf(w, "[Recovery] %s panic recovered: %v request_id=%s method=%s route=%s\n%s\n",
+				time.Now().Format("2006/01/02 - 15:04:05"), r, RequestID(c), c.Request.Method, routeOf(c), stack)
+			if report != nil {
+				report(c, r, stack)
+			}
+
+			if isBrokenPipe(r) || c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			ErrorResponse(c, ErrorInternalServerError.Wrap(fmt.Errorf("panic: %v", r)))
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+// routeOf route template, e.g. /orders/:id, path if not matched
+func routeOf(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return c.Request.URL.Path
+}
+
+// isBrokenPipe client connection closed, can not write response
+func isBrokenPipe(r interface{}) bool {
+	err, ok := r.(error)
+	if !ok {
+		return false
+	}
+	var ne *net.OpError
+	if !errors.As(err, &ne) {
+		return false
+	}
+	var se *os.SyscallError
+	if !errors.As(ne, &se) {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
@@ -0,0 +1,51 @@
+package cbl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var (
+		logs     bytes.Buffer
+		reported interface{}
+	)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RecoveryMiddleware(&RecoveryOptions{
+		Writer: &logs,
+		Report: func(c *gin.Context, recovered interface{}, stack []byte) {
+			reported = recovered
+		},
+	}))
+	router.GET("/orders/:id", func(c *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("after write")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, `{"code":50000,"data":null,"error":"Internal Server Error"}`, w.Body.String())
+	assert.Equal(t, "boom", reported)
+	assert.Contains(t, logs.String(), "panic recovered: boom request_id=req-1 method=GET route=/orders/:id")
+	assert.Contains(t, logs.String(), "recovery_test.go")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/written", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Equal(t, "after write", reported)
+}
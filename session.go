@@ -0,0 +1,126 @@
+package cbl
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+const (
+	SessionCookieName = "session_id"
+	SessionHeaderName = "X-Session-ID"
+
+	sessionContextKey = "_cbl_session_"
+)
+
+// SessionManager server-side session manager, session id generated by GenRSessionID,
+// carried by cookie (web) or header (mini program/app).
+type SessionManager struct {
+	TTL        time.Duration // sliding expiration, session expired if not accessed in TTL
+	CookieName string
+	HeaderName string
+
+	// cookie options
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieHTTPOnly bool
+}
+
+// NewSessionManager session manager with default cookie/header name
+func NewSessionManager(ttl time.Duration) *SessionManager {
+	return &SessionManager{
+		TTL:            ttl,
+		CookieName:     SessionCookieName,
+		HeaderName:     SessionHeaderName,
+		CookiePath:     "/",
+		CookieHTTPOnly: true,
+	}
+}
+
+// Create create session for user after login, set cookie and response header
+func (m *SessionManager) Create(c *gin.Context, userID string) (*cache.Session, error) {
+	s, err := cache.SessionCreate(GenRSessionID(), userID, m.TTL)
+	if err != nil {
+		return nil, err
+	}
+	m.setCookie(c, s.ID, int(m.TTL.Seconds()))
+	c.Header(m.HeaderName, s.ID)
+	c.Set(sessionContextKey, s)
+	return s, nil
+}
+
+// Destroy revoke current session (log out), clear cookie
+func (m *SessionManager) Destroy(c *gin.Context) error {
+	id := m.sessionID(c)
+	if id == "" {
+		return nil
+	}
+	m.setCookie(c, "", -1)
+	return cache.SessionRevoke(id)
+}
+
+// List list user's alive sessions
+func (m *SessionManager) List(userID string) ([]*cache.Session, error) {
+	return cache.SessionUserList(userID)
+}
+
+// RevokeUser revoke all sessions of user (log out everywhere), return revoked count
+func (m *SessionManager) RevokeUser(userID string) (int64, error) {
+	return cache.SessionUserRevoke(userID)
+}
+
+func (m *SessionManager) setCookie(c *gin.Context, value string, maxAge int) {
+	c.SetCookie(m.CookieName, value, maxAge, m.CookiePath, m.CookieDomain, m.CookieSecure, m.CookieHTTPOnly)
+}
+
+func (m *SessionManager) sessionID(c *gin.Context) string {
+	if id := c.GetHeader(m.HeaderName); id != "" {
+		return id
+	}
+	id, _ := c.Cookie(m.CookieName)
+	return id
+}
+
+// Middleware load session from header or cookie (header first), response ErrorLoginRequired (401)
+// when session absent or expired. get session in handler by `GetSession(c)`.
+// cookie MaxAge renewed along with the sliding expiration of session.
+func (m *SessionManager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := m.sessionID(c)
+		if id == "" {
+			ErrorResponse(c, ErrorLoginRequired)
+			c.Abort()
+			return
+		}
+
+		s, err := cache.SessionGet(id, m.TTL)
+		if err == cache.NotExist {
+			ErrorResponse(c, ErrorLoginRequired)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			ErrorResponse(c, ErrorInternalServerError.Wrap(err))
+			c.Abort()
+			return
+		}
+
+		if cookie, _ := c.Cookie(m.CookieName); cookie == id {
+			m.setCookie(c, id, int(m.TTL.Seconds()))
+		}
+		c.Set(sessionContextKey, s)
+		c.Next()
+	}
+}
+
+// GetSession get session loaded by SessionManager middleware, nil if not logged in
+func GetSession(c *gin.Context) *cache.Session {
+	v, ok := c.Get(sessionContextKey)
+	if !ok {
+		return nil
+	}
+	s, _ := v.(*cache.Session)
+	return s
+}
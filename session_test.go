@@ -0,0 +1,81 @@
+package cbl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionManager(t *testing.T) {
+	var (
+		userID = "TestSessionManagerUser"
+		m      = NewSessionManager(time.Minute)
+	)
+	defer m.RevokeUser(userID)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", func(c *gin.Context) {
+		s, err := m.Create(c, userID)
+		if err != nil {
+			ErrorResponse(c, err)
+			return
+		}
+		s.Set("nickname", "Bob")
+		SuccessResponse(c, nil)
+	})
+	auth := router.Group("/", m.Middleware())
+	auth.GET("/me", func(c *gin.Context) {
+		s := GetSession(c)
+		SuccessResponse(c, s.GetString("nickname"))
+	})
+	auth.POST("/logout", func(c *gin.Context) {
+		m.Destroy(c)
+		SuccessResponse(c, nil)
+	})
+
+	do := func(method, path string, cookie *http.Cookie, header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if header != "" {
+			req.Header.Set(SessionHeaderName, header)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/me", nil, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"code":40100,"data":null,"error":"Login Required"}`, w.Body.String())
+
+	w = do(http.MethodPost, "/login", nil, "")
+	cookies := w.Result().Cookies()
+	require.Equal(t, 1, len(cookies))
+	cookie := cookies[0]
+	assert.Equal(t, cookie.Value, w.Header().Get(SessionHeaderName))
+
+	w = do(http.MethodGet, "/me", cookie, "")
+	assert.Equal(t, `{"code":0,"data":"Bob","error":""}`, w.Body.String())
+	// cookie renewed with session
+	cookies = w.Result().Cookies()
+	require.Equal(t, 1, len(cookies))
+	assert.Equal(t, cookie.Value, cookies[0].Value)
+	assert.Equal(t, 60, cookies[0].MaxAge)
+
+	w = do(http.MethodGet, "/me", nil, cookie.Value)
+	assert.Equal(t, `{"code":0,"data":"Bob","error":""}`, w.Body.String())
+	assert.Equal(t, 0, len(w.Result().Cookies()))
+
+	do(http.MethodPost, "/logout", cookie, "")
+	w = do(http.MethodGet, "/me", cookie, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"code":40100,"data":null,"error":"Login Required"}`, w.Body.String())
+}
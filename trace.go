@@ -0,0 +1,238 @@
+package cbl
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -----------------------------------------------------------------------------
+// request id and W3C trace context (https://www.w3.org/TR/trace-context/) propagation
+//
+//	router.Use(cbl.RequestIDMiddleware(nil))
+//	client := &http.Client{Transport: &cbl.TraceTransport{}}
+//	req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, url, nil)
+//	client.Do(req) // request id and traceparent forwarded
+// -----------------------------------------------------------------------------
+
+const (
+	RequestIDHeader   = "X-Request-Id"
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const (
+	requestIDContextKey = "_cbl_request_id_"
+	traceContextKey     = "_cbl_trace_"
+	requestIDInBodyKey  = "_cbl_request_id_in_body_"
+)
+
+type ctxKey int
+
+const (
+	ctxKeyRequestID ctxKey = iota
+	ctxKeyTrace
+)
+
+// TraceContext W3C trace context of current request
+type TraceContext struct {
+	TraceID  string // 32 hex
+	ParentID string // span id of caller, empty if trace started here
+	SpanID   string // 16 hex, span id of current service
+	Flags    byte   // trace flags, 01 sampled
+	State    string // tracestate, forwarded as is
+}
+
+// ParseTraceparent parse `traceparent` header, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+// SpanID of result is the parent id in header (span id of caller).
+func ParseTraceparent(s string) (*TraceContext, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("invalid traceparent %q", s)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// version 00 exactly 4 fields, future versions may append fields
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return nil, fmt.Errorf("invalid traceparent version %q", s)
+	}
+	if !isHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return nil, fmt.Errorf("invalid traceparent trace id %q", s)
+	}
+	if !isHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return nil, fmt.Errorf("invalid traceparent parent id %q", s)
+	}
+	if !isHex(flags, 2) {
+		return nil, fmt.Errorf("invalid traceparent flags %q", s)
+	}
+	b, _ := hex.DecodeString(flags)
+	return &TraceContext{TraceID: traceID, SpanID: spanID, Flags: b[0]}, nil
+}
+
+// isHex lower case hex string of length n
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Traceparent header value of current span
+func (t *TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceID, t.SpanID, t.Flags)
+}
+
+// Sampled sampled flag set
+func (t *TraceContext) Sampled() bool {
+	return t.Flags&0x01 == 0x01
+}
+
+// newServerTrace continue trace of caller with a new span, or start a new trace
+func newServerTrace(traceparent string, tracestate string) *TraceContext {
+	parent, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return &TraceContext{TraceID: GenTraceID(), SpanID: GenSpanID()}
+	}
+	return &TraceContext{
+		TraceID:  parent.TraceID,
+		ParentID: parent.SpanID,
+		SpanID:   GenSpanID(),
+		Flags:    parent.Flags,
+		State:    tracestate,
+	}
+}
+
+// ContextWithRequestID context carry request id, e.g. for background jobs
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestID, requestID)
+}
+
+// RequestIDFromContext request id in context, empty if not set
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyRequestID).(string)
+	return id
+}
+
+// ContextWithTrace context carry trace context
+func ContextWithTrace(ctx context.Context, t *TraceContext) context.Context {
+	return context.WithValue(ctx, ctxKeyTrace, t)
+}
+
+// TraceFromContext trace context in context, nil if not set
+func TraceFromContext(ctx context.Context) *TraceContext {
+	t, _ := ctx.Value(ctxKeyTrace).(*TraceContext)
+	return t
+}
+
+// RequestIDOptions options of RequestIDMiddleware
+type RequestIDOptions struct {
+	Generator func() string // request id generator, GenUUIDV4 if nil
+	InBody    bool          // also response request id in Resp body (`request_id`)
+}
+
+// RequestIDMiddleware accept request id from header `X-Request-Id` or generate one,
+// continue W3C trace context from `traceparent` (new span) or start a new trace.
+// both stored in gin context and request context, echoed in response headers.
+func RequestIDMiddleware(opts *RequestIDOptions) gin.HandlerFunc {
+	var (
+		gen    = GenUUIDV4
+		inBody bool
+	)
+	if opts != nil {
+		if opts.Generator != nil {
+			gen = opts.Generator
+		}
+		inBody = opts.InBody
+	}
+
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(RequestIDHeader))
+		if id == "" || len(id) > 128 {
+			id = gen()
+		}
+		trace := newServerTrace(c.GetHeader(TraceparentHeader), c.GetHeader(TracestateHeader))
+
+		c.Set(requestIDContextKey, id)
+		c.Set(traceContextKey, trace)
+		if inBody {
+			c.Set(requestIDInBodyKey, true)
+		}
+		ctx := ContextWithTrace(ContextWithRequestID(c.Request.Context(), id), trace)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Header(RequestIDHeader, id)
+		c.Header(TraceparentHeader, trace.Traceparent())
+		c.Next()
+	}
+}
+
+// RequestID request id of RequestIDMiddleware, request header `X-Request-Id` if middleware not used
+func RequestID(c *gin.Context) string {
+	if v, ok := c.Get(requestIDContextKey); ok {
+		if id, ok := v.(string); ok {
+			return id
+		}
+	}
+	return c.GetHeader(RequestIDHeader)
+}
+
+// Trace trace context of RequestIDMiddleware, nil if middleware not used
+func Trace(c *gin.Context) *TraceContext {
+	v, ok := c.Get(traceContextKey)
+	if !ok {
+		return nil
+	}
+	t, _ := v.(*TraceContext)
+	return t
+}
+
+// responseRequestID request id in Resp body if enabled
+func responseRequestID(c *gin.Context) string {
+	if c.GetBool(requestIDInBodyKey) {
+		return RequestID(c)
+	}
+	return ""
+}
+
+// TraceTransport outbound http.RoundTripper forward request id and trace context of
+// request context (not overwrite headers already set)
+type TraceTransport struct {
+	Base http.RoundTripper // http.DefaultTransport if nil
+}
+
+// RoundTrip implement http.RoundTripper
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx := req.Context()
+	id := RequestIDFromContext(ctx)
+	trace := TraceFromContext(ctx)
+	if id == "" && trace == nil {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTripper should not modify request
+	req = req.Clone(ctx)
+	if id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if trace != nil && req.Header.Get(TraceparentHeader) == "" {
+		req.Header.Set(TraceparentHeader, trace.Traceparent())
+		if trace.State != "" {
+			req.Header.Set(TracestateHeader, trace.State)
+		}
+	}
+	return base.RoundTrip(req)
+}
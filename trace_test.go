@@ -0,0 +1,116 @@
+package cbl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.Nil(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", tc.SpanID)
+	assert.True(t, tc.Sampled())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tc.Traceparent())
+
+	// future version with more fields
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.Nil(t, err)
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	} {
+		_, err = ParseTraceparent(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var outbound http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Clone()
+	}))
+	defer downstream.Close()
+	client := &http.Client{Transport: &TraceTransport{}}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware(&RequestIDOptions{InBody: true}))
+	router.GET("/orders", func(c *gin.Context) {
+		req, _ := http.NewRequest(http.MethodGet, downstream.URL, nil)
+		resp, err := client.Do(req.WithContext(c.Request.Context()))
+		if err != nil {
+			ErrorResponse(c, err)
+			return
+		}
+		resp.Body.Close()
+		SuccessResponse(c, RequestIDFromContext(c.Request.Context()) == RequestID(c))
+	})
+
+	do := func(requestID string, traceparent string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(RequestIDHeader, requestID)
+		req.Header.Set(TraceparentHeader, traceparent)
+		req.Header.Set(TracestateHeader, "vendor=1")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// continue trace of caller
+	w := do("req-1", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, `{"code":0,"data":true,"error":"","request_id":"req-1"}`, w.Body.String())
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+	tc, err := ParseTraceparent(w.Header().Get(TraceparentHeader))
+	require.Nil(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.NotEqual(t, "00f067aa0ba902b7", tc.SpanID)
+	assert.True(t, tc.Sampled())
+	assert.Equal(t, "req-1", outbound.Get(RequestIDHeader))
+	assert.Equal(t, w.Header().Get(TraceparentHeader), outbound.Get(TraceparentHeader))
+	assert.Equal(t, "vendor=1", outbound.Get(TracestateHeader))
+
+	// start new
+	w = do("", "invalid")
+	id := w.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 36)
+	assert.Contains(t, w.Body.String(), `"request_id":"`+id+`"`)
+	tc, err = ParseTraceparent(w.Header().Get(TraceparentHeader))
+	require.Nil(t, err)
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.Equal(t, id, outbound.Get(RequestIDHeader))
+	assert.Equal(t, "", outbound.Get(TracestateHeader))
+}
+
+func TestTraceTransportWithoutContext(t *testing.T) {
+	var outbound http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Clone()
+	}))
+	defer downstream.Close()
+
+	client := &http.Client{Transport: &TraceTransport{}}
+	req, _ := http.NewRequest(http.MethodGet, downstream.URL, nil)
+	ctx := ContextWithRequestID(context.Background(), "job-1")
+	req = req.WithContext(ctx)
+	req.Header.Set(RequestIDHeader, "explicit")
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "explicit", outbound.Get(RequestIDHeader))
+	assert.Equal(t, "", outbound.Get(TraceparentHeader))
+	assert.Nil(t, TraceFromContext(ctx))
+}
@@ -0,0 +1,201 @@
+package cbl
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// -----------------------------------------------------------------------------
+// validation error translation, binding errors to ErrInvalidParams with per-field
+// localized messages as Resp.Data, field named by go field name, or json (or form/uri/header)
+// tag after UseJSONFieldNames.
+//
+//	cbl.UseJSONFieldNames() // once at startup
+//
+//	var req CreateOrderReq
+//	if err := c.ShouldBind(&req); err != nil {
+//		cbl.BindErrorResponse(c, err)
+//		return
+//	}
+//
+//	// {"code":40001,"data":{"name":"name is required"},"error":"Invalid Params"}
+//
+// message templates keyed by validation tag, kind specific ones by `tag:kind`
+// (kind: string, number, items), `{field}` and `{param}` placeholders.
+// -----------------------------------------------------------------------------
+
+// validationDefault template key of tags without messages
+const validationDefault = ""
+
+func init() {
+	builtin := map[string]Messages{
+		validationDefault: {LocaleEn: "{field} is invalid", LocaleZh: "{field}格式错误"},
+		"type":            {LocaleEn: "{field} must be {param}", LocaleZh: "{field}类型必须是 {param}"},
+		"required":        {LocaleEn: "{field} is required", LocaleZh: "{field}不能为空"},
+		"email":           {LocaleEn: "{field} must be a valid email address", LocaleZh: "{field}必须是有效的邮箱地址"},
+		"url":             {LocaleEn: "{field} must be a valid URL", LocaleZh: "{field}必须是有效的 URL"},
+		"uuid":            {LocaleEn: "{field} must be a valid UUID", LocaleZh: "{field}必须是有效的 UUID"},
+		"numeric":         {LocaleEn: "{field} must be numeric", LocaleZh: "{field}必须是数字"},
+		"alphanum":        {LocaleEn: "{field} must be alphanumeric", LocaleZh: "{field}只能包含字母和数字"},
+		"oneof":           {LocaleEn: "{field} must be one of [{param}]", LocaleZh: "{field}必须是 [{param}] 中的一个"},
+		"eq":              {LocaleEn: "{field} must equal {param}", LocaleZh: "{field}必须等于 {param}"},
+		"ne":              {LocaleEn: "{field} must not equal {param}", LocaleZh: "{field}不能等于 {param}"},
+		"eqfield":         {LocaleEn: "{field} must equal {param}", LocaleZh: "{field}必须与 {param} 相同"},
+		"gt":              {LocaleEn: "{field} must be greater than {param}", LocaleZh: "{field}必须大于 {param}"},
+		"lt":              {LocaleEn: "{field} must be less than {param}", LocaleZh: "{field}必须小于 {param}"},
+		"len:string":      {LocaleEn: "{field} must be {param} characters long", LocaleZh: "{field}长度必须是 {param} 个字符"},
+		"len:number":      {LocaleEn: "{field} must equal {param}", LocaleZh: "{field}必须等于 {param}"},
+		"len:items":       {LocaleEn: "{field} must contain {param} items", LocaleZh: "{field}必须包含 {param} 项"},
+		"min:string":      {LocaleEn: "{field} must be at least {param} characters", LocaleZh: "{field}长度不能少于 {param} 个字符"},
+		"min:number":      {LocaleEn: "{field} must be {param} or greater", LocaleZh: "{field}不能小于 {param}"},
+		"min:items":       {LocaleEn: "{field} must contain at least {param} items", LocaleZh: "{field}至少包含 {param} 项"},
+		"max:string":      {LocaleEn: "{field} must be at most {param} characters", LocaleZh: "{field}长度不能超过 {param} 个字符"},
+		"max:number":      {LocaleEn: "{field} must be {param} or less", LocaleZh: "{field}不能大于 {param}"},
+		"max:items":       {LocaleEn: "{field} must contain at most {param} items", LocaleZh: "{field}最多包含 {param} 项"},
+	}
+	for _, alias := range [][2]string{{"gte", "min"}, {"lte", "max"}} {
+		for _, kind := range []string{"string", "number", "items"} {
+			builtin[alias[0]+":"+kind] = builtin[alias[1]+":"+kind]
+		}
+	}
+	for tag, messages := range builtin {
+		RegisterValidationMessages(tag, messages)
+	}
+}
+
+// UseJSONFieldNames name fields by json/form/uri/header tag in validation errors of gin binding
+// validator (FieldError.Field/Namespace, TranslateBindError keys and messages), e.g. `address.city`
+// instead of `Address.City`. it changes gin global validator, call once at startup before binding.
+func UseJSONFieldNames() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldTagName)
+	}
+}
+
+// RegisterValidationMessages register (merge) message templates of validation tag,
+// e.g. custom validation, or `tag:kind` for kind specific one
+func RegisterValidationMessages(tag string, messages Messages) {
+	registry.Lock()
+	defer registry.Unlock()
+	m, ok := registry.validations[tag]
+	if !ok {
+		m = Messages{}
+		registry.validations[tag] = m
+	}
+	registry.merge(m, messages)
+}
+
+// TranslateBindError field -> localized message of binding error, nil if not a validation
+// or json type error (e.g. malformed json)
+func TranslateBindError(err error, locale string) map[string]string {
+	var (
+		ves validator.ValidationErrors
+		ute *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &ves):
+		fields := make(map[string]string, len(ves))
+		for _, fe := range ves {
+			name := fieldPath(fe.Namespace())
+			if _, ok := fields[name]; ok {
+				continue
+			}
+			fields[name] = validationMessage(locale, fe.Tag(), fieldKind(fe.Kind()), fe.Field(), fe.Param())
+		}
+		return fields
+	case errors.As(err, &ute):
+		name := ute.Field
+		if name == "" {
+			return nil
+		}
+		return map[string]string{
+			name: validationMessage(locale, "type", "", name[strings.LastIndexByte(name, '.')+1:], ute.Type.String()),
+		}
+	}
+	return nil
+}
+
+// BindError ErrorInvalidParams wraps binding error, with field messages of the request locale as details
+func BindError(c *gin.Context, err error) *Error {
+	e := ErrorInvalidParams.Wrap(err)
+	if fields := TranslateBindError(err, Locale(c)); fields != nil {
+		e.Details = fields
+	}
+	return e
+}
+
+// BindErrorResponse response binding error, see BindError
+func BindErrorResponse(c *gin.Context, err error) {
+	ErrorResponse(c, BindError(c, err))
+}
+
+func validationMessage(locale string, tag string, kind string, field string, param string) string {
+	registry.RLock()
+	defer registry.RUnlock()
+	keys := []string{tag, validationDefault}
+	if kind != "" {
+		keys = []string{tag + ":" + kind, tag, validationDefault}
+	}
+	for _, key := range keys {
+		m, ok := registry.validations[key]
+		if !ok {
+			continue
+		}
+		if tmpl, ok := m.pick(locale); ok {
+			return renderMessage(tmpl, map[string]interface{}{"field": field, "param": param})
+		}
+	}
+	return field
+}
+
+func fieldKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return ""
+}
+
+// embeddedField name of untagged embedded struct, fields flattened like json
+const embeddedField = "~embedded"
+
+// fieldPath namespace without top struct and embedded struct, e.g. "Req.address.city" -> "address.city"
+func fieldPath(namespace string) string {
+	parts := strings.Split(namespace, ".")
+	path := make([]string, 0, len(parts))
+	for _, p := range parts[1:] {
+		if p != embeddedField {
+			path = append(path, p)
+		}
+	}
+	if len(path) == 0 {
+		return namespace
+	}
+	return strings.Join(path, ".")
+}
+
+// fieldTagName name of field by json/form/uri/header tag, go field name if no tag
+func fieldTagName(f reflect.StructField) string {
+	if f.Anonymous && f.Tag.Get("json") == "" {
+		return embeddedField
+	}
+	for _, key := range []string{"json", "form", "uri", "header"} {
+		name := strings.SplitN(f.Tag.Get(key), ",", 2)[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
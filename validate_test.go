@@ -0,0 +1,91 @@
+package cbl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindErrorResponse(t *testing.T) {
+	UseJSONFieldNames()
+	type Address struct {
+		City string `json:"city" binding:"required"`
+	}
+	type CreateOrderReq struct {
+		Name     string   `json:"name" binding:"required"`
+		Email    string   `json:"email" binding:"omitempty,email"`
+		Count    int      `json:"count" binding:"min=1,max=10"`
+		Tags     []string `json:"tags" binding:"max=2"`
+		Status   string   `json:"status" binding:"omitempty,oneof=new paid"`
+		Address  Address  `json:"address"`
+		Internal string   `json:"-" form:"internal" binding:"omitempty,len=3"`
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		var req CreateOrderReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BindErrorResponse(c, err)
+			return
+		}
+		SuccessResponse(c, req.Name)
+	})
+
+	do := func(body string, lang string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", lang)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(`{"email":"bad","count":0,"tags":["a","b","c"],"status":"done"}`, "en")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"code":40001,"error":"Invalid Params","data":{
+		"name":"name is required",
+		"email":"email must be a valid email address",
+		"count":"count must be 1 or greater",
+		"tags":"tags must contain at most 2 items",
+		"status":"status must be one of [new paid]",
+		"address.city":"city is required"
+	}}`, w.Body.String())
+
+	w = do(`{"name":"a","count":11,"address":{"city":"sh"}}`, "zh-CN,zh;q=0.9")
+	assert.JSONEq(t, `{"code":40001,"error":"参数格式错误","data":{"count":"count不能大于 10"}}`, w.Body.String())
+
+	w = do(`{"name":"a","count":"1"}`, "en")
+	assert.JSONEq(t, `{"code":40001,"error":"Invalid Params","data":{"count":"count must be int"}}`, w.Body.String())
+
+	w = do(`{"name":`, "en")
+	assert.JSONEq(t, `{"code":40001,"error":"Invalid Params","data":null}`, w.Body.String())
+
+	w = do(`{"name":"a","count":1,"address":{"city":"sh"}}`, "en")
+	assert.Equal(t, `{"code":0,"data":"a","error":""}`, w.Body.String())
+}
+
+func TestTranslateBindErrorCustom(t *testing.T) {
+	UseJSONFieldNames()
+	RegisterValidationMessages("required", Messages{"ja": "{field}は必須です"})
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		delete(registry.validations["required"], "ja")
+		delete(registry.locales, "ja")
+	})
+	type Req struct {
+		Name string `form:"name" binding:"required"`
+	}
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/?x=1", nil)
+	var req Req
+	err := c.ShouldBindQuery(&req)
+	assert.Equal(t, map[string]string{"name": "nameは必須です"}, TranslateBindError(err, "ja-JP"))
+	assert.Nil(t, TranslateBindError(nil, "en"))
+}
@@ -0,0 +1,98 @@
+package cbl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zhangjie2012/cbl-go/cache"
+)
+
+var (
+	ErrVerifyCodeInvalid  = ErrorInvalidVerifyCode
+	ErrVerifyCodeCooldown = cache.ErrVCodeCooldown
+	ErrVerifyCodeQuota    = cache.ErrVCodeQuota
+)
+
+// VerifyCodeSender send code to target by SMS/email etc.
+type VerifyCodeSender interface {
+	Send(scene string, target string, code string, ttl time.Duration) error
+}
+
+// VerifyCodeSenderFunc function as VerifyCodeSender
+type VerifyCodeSenderFunc func(scene string, target string, code string, ttl time.Duration) error
+
+func (f VerifyCodeSenderFunc) Send(scene string, target string, code string, ttl time.Duration) error {
+	return f(scene, target, code, ttl)
+}
+
+// VerifyCodeManager verify code lifecycle: issue, send, verify.
+// code issued for (scene, target), e.g. ("login", "136****1234"), daily quota counted by target
+// across scenes (prevent SMS bombing).
+type VerifyCodeManager struct {
+	Sender      VerifyCodeSender
+	Generate    func(width int) string // code generator, default GenVerifyCodeNumber
+	Width       int                    // code width
+	TTL         time.Duration          // code expire duration
+	Cooldown    time.Duration          // resend cooldown
+	DailyQuota  int64                  // max send count of a target per day, 0 for unlimited
+	MaxAttempts int64                  // max verify attempts, code invalidated after all failed
+}
+
+// NewVerifyCodeManager 6 numbers code, expired in 5 minutes, resend after 1 minute,
+// max 10 per day, max 5 verify attempts.
+func NewVerifyCodeManager(sender VerifyCodeSender) *VerifyCodeManager {
+	return &VerifyCodeManager{
+		Sender:      sender,
+		Generate:    GenVerifyCodeNumber,
+		Width:       6,
+		TTL:         5 * time.Minute,
+		Cooldown:    time.Minute,
+		DailyQuota:  10,
+		MaxAttempts: 5,
+	}
+}
+
+func (m *VerifyCodeManager) key(scene string, target string) string {
+	return fmt.Sprintf("%s.%s", scene, target)
+}
+
+// Send issue a new code (replace the old one) and send it.
+// ErrVerifyCodeCooldown if resend in cooldown, ErrVerifyCodeQuota if reach daily quota.
+// cooldown and quota are given back if send failure.
+func (m *VerifyCodeManager) Send(scene string, target string) error {
+	var (
+		key  = m.key(scene, target)
+		code = m.Generate(m.Width)
+	)
+	err := cache.VCodeIssue(key, target, code, &cache.VCodeOptions{
+		TTL:           m.TTL,
+		Cooldown:      m.Cooldown,
+		MaxAttempts:   m.MaxAttempts,
+		Quota:         m.DailyQuota,
+		QuotaExpireAt: EndOfDay(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.Sender.Send(scene, target, code, m.TTL); err != nil {
+		cache.VCodeRevoke(key, target)
+		return err
+	}
+	return nil
+}
+
+// RemainingCooldown remaining resend cooldown, 0 for can send now
+func (m *VerifyCodeManager) RemainingCooldown(scene string, target string) time.Duration {
+	return cache.VCodeCooldown(m.key(scene, target))
+}
+
+// Verify check code, code consumed on success.
+// ErrVerifyCodeInvalid if code not match, expired or all attempts failed.
+func (m *VerifyCodeManager) Verify(scene string, target string, code string) error {
+	err := cache.VCodeVerify(m.key(scene, target), code)
+	if err == cache.NotExist || err == cache.ErrVCodeMismatch {
+		return ErrVerifyCodeInvalid
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package cbl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCodeManager(t *testing.T) {
+	var (
+		scene  = "login"
+		target = "TestVerifyCodeManager"
+		sent   = ""
+		fail   = false
+	)
+
+	m := NewVerifyCodeManager(VerifyCodeSenderFunc(func(scene, target, code string, ttl time.Duration) error {
+		if fail {
+			return errors.New("sms failure")
+		}
+		sent = code
+		return nil
+	}))
+	m.Cooldown = 50 * time.Millisecond
+	m.DailyQuota = 0
+
+	fail = true
+	err := m.Send(scene, target)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 0, m.RemainingCooldown(scene, target)) // given back
+
+	fail = false
+	err = m.Send(scene, target)
+	require.Nil(t, err)
+	assert.Equal(t, 6, len(sent))
+	assert.Equal(t, ErrVerifyCodeCooldown, m.Send(scene, target))
+
+	assert.Equal(t, ErrVerifyCodeInvalid, m.Verify(scene, target, "abcdef"))
+	assert.Nil(t, m.Verify(scene, target, sent))
+	assert.Equal(t, ErrVerifyCodeInvalid, m.Verify(scene, target, sent))
+}
@@ -2,21 +2,40 @@ package cbl
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/zhangjie2012/cbl-go/cache"
+	"github.com/zhangjie2012/cbl-go/cache/cachetest"
 )
 
 func TestMain(m *testing.M) {
 
 	var (
 		name      string = "cblcache"
-		redisAddr string = "localhost:6379"
+		redisAddr string = os.Getenv("CBL_TEST_REDIS_ADDR") // test with real redis, e.g. localhost:6379
 		password  string = ""
 		db        int    = 0
 	)
 
+	// hermetic by default
+	if redisAddr == "" {
+		srv, err := cachetest.NewServer()
+		if err != nil {
+			fmt.Printf("fake redis start failure, err=%s", err)
+			return
+		}
+		redisAddr = srv.Addr()
+	}
+
+	// fake yinyang api
+	fake, _ := url.Parse(fakeYinYangServer().URL)
+	http.DefaultTransport = &yinyangTransport{fake: fake, base: http.DefaultTransport}
+
 	if err := cache.InitCache(name, redisAddr, password, db); err != nil {
 		fmt.Printf("redis init failure, err=%s", err)
 		return
@@ -29,6 +48,39 @@ func TestMain(m *testing.M) {
 	os.Exit(ec)
 }
 
+// yinyangTransport redirect requests of yinyang api to the fake server
+type yinyangTransport struct {
+	fake *url.URL
+	base http.RoundTripper
+}
+
+func (t *yinyangTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.URL.String(), yyServer) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme, req.URL.Host = t.fake.Scheme, t.fake.Host
+	}
+	return t.base.RoundTrip(req)
+}
+
+func fakeYinYangServer() *httptest.Server {
+	responses := map[string]string{
+		"/yinyang/api/v1/conv/yin-yang/2020/5/0/5": `{"code":0,"data":{"year":2020,"month":6,"day":25}}`,
+		"/yinyang/api/v1/conv/yang-yin/2020/6/25": `{"code":0,"data":{"year_num":2020,"year_tian":"庚","year_di":"子",` +
+			`"year_zodiac":"鼠","month_num":5,"month_name":"五月","month_leap":false,"day_num":5,"day_name":"初五",` +
+			`"weekday":"星期四","solarterm":""}}`,
+		"/yinyang/api/v1/conv/yang-yin/2020/6/26": `{"code":0,"data":{"year_num":2020,"year_tian":"庚","year_di":"子",` +
+			`"year_zodiac":"鼠","month_num":5,"month_name":"五月","month_leap":false,"day_num":6,"day_name":"初六",` +
+			`"weekday":"星期五","solarterm":""}}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			resp = `{"code":1,"error":"not found"}`
+		}
+		w.Write([]byte(resp))
+	}))
+}
+
 func TestToString(t *testing.T) {
 	y, _ := ConvYangYin(2020, 6, 25)
 	t.Log(y.ToString1())